@@ -1,9 +1,6 @@
 package hyliocache
 
-import (
-	"container/list"
-	"time"
-)
+import "container/list"
 
 type ARCCache struct {
 	baseCache
@@ -20,55 +17,42 @@ func newARCCache(cb *CacheBuilder) *ARCCache {
 	buildCache(&c.baseCache, cb)
 	c.init()
 	c.group.cache = c
+	c.store = c
+	c.startJanitor(cb.cleanupInterval)
 	return c
 }
 
 func (c *ARCCache) init() {
 	c.items = make(map[interface{}]*arcItem)
+	c.part = 0
 	c.t1 = newArcList()
 	c.t2 = newArcList()
 	c.b1 = newArcList()
 	c.b2 = newArcList()
 }
 
-func (c *ARCCache) Set(key, value interface{}) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	_, err := c.set(key, value)
-	return err
-}
-
-func (c *ARCCache) SetWithExpire(key, value interface{}, expiration time.Duration) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	item, err := c.set(key, value)
+func (c *ARCCache) set(key, value interface{}) (*cacheItem, error) {
+	w, err := c.weigh(key, value)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	t := c.clock.Now().Add(expiration)
-	item.(*arcItem).expiration = &t
-	return nil
-}
-
-func (c *ARCCache) set(key, value interface{}) (interface{}, error) {
 	item, ok := c.items[key]
 	if ok {
-		item.value = value
+		c.replaceValue(&item.cacheItem, value)
 	} else {
 		item = &arcItem{
-			clock: c.clock,
-			key:   key,
-			value: value,
+			cacheItem: c.newItem(key, value),
 		}
 		c.items[key] = item
 	}
-	if c.expiration != nil {
-		t := c.clock.Now().Add(*c.expiration)
-		item.expiration = &t
-	}
-
-	if c.t1.Has(key) && c.t2.Has(key) {
-		return item, nil
+	c.written(&item.cacheItem)
+	c.setWeight(&item.cacheItem, w)
+	c.evictOverweight(key)
+
+	// 已经在缓存中的元素只需要更新值
+	if c.t1.Has(key) || c.t2.Has(key) {
+		c.added(key, value)
+		return &item.cacheItem, nil
 	}
 
 	if ele := c.b1.Get(key); ele != nil {
@@ -76,7 +60,8 @@ func (c *ARCCache) set(key, value interface{}) (interface{}, error) {
 		c.replace(key)
 		c.b1.Remove(key, ele)
 		c.t2.PushFront(key)
-		return item, nil
+		c.added(key, value)
+		return &item.cacheItem, nil
 	}
 
 	if ele := c.b2.Get(key); ele != nil {
@@ -84,21 +69,20 @@ func (c *ARCCache) set(key, value interface{}) (interface{}, error) {
 		c.replace(key)
 		c.b2.Remove(key, ele)
 		c.t2.PushFront(key)
-		return item, nil
+		c.added(key, value)
+		return &item.cacheItem, nil
 	}
 
 	if c.isCacheFull() && c.t1.Len()+c.b1.Len() == c.size {
 		if c.t1.Len() < c.size {
 			c.b1.RemoveTail()
 			c.replace(key)
-		} else {
+		} else if c.removeExpired() == 0 {
 			pop := c.t1.RemoveTail()
 			item, ok := c.items[pop]
 			if ok {
 				delete(c.items, pop)
-				if c.evictedFunc != nil {
-					c.evictedFunc(item.key, item.value)
-				}
+				c.removed(&item.cacheItem, RemovalSize)
 			}
 		}
 	} else {
@@ -115,179 +99,68 @@ func (c *ARCCache) set(key, value interface{}) (interface{}, error) {
 		}
 	}
 
-	if c.addedFunc != nil {
-		c.addedFunc(key, value)
-	}
+	c.added(key, value)
 	c.t1.PushFront(key)
-	return item, nil
-}
-
-func (c *ARCCache) Get(key interface{}) (interface{}, error) {
-	item, err := c.get(key, false)
-	if err == KeyNotFoundError {
-		return c.getWithLoader(key, true)
-	}
-	return item, nil
-}
-
-func (c *ARCCache) GetALL(checkExpired bool) map[interface{}]interface{} {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	items := make(map[interface{}]interface{}, len(c.items))
-	now := time.Now()
-	for k, item := range c.items {
-		if !checkExpired || c.has(k, &now) {
-			items[k] = item.value
-		}
-	}
-	return items
+	return &item.cacheItem, nil
 }
 
-func (c *ARCCache) GetIfPresent(key interface{}) (interface{}, error) {
-	v, err := c.get(key, false)
-	if err == KeyNotFoundError {
-		return c.getWithLoader(key, false)
-	}
-	return v, nil
-}
-
-func (c *ARCCache) get(key interface{}, onLoad bool) (interface{}, error) {
-	v, err := c.getValue(key, onLoad)
-	if err != nil {
-		return nil, err
-	}
-	return v, nil
-}
-
-func (c *ARCCache) getValue(key interface{}, onLoad bool) (interface{}, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// lookup 查找未过期的元素 调用时需持有 mu
+func (c *ARCCache) lookup(key interface{}) (*cacheItem, bool) {
 	if ele := c.t1.Get(key); ele != nil {
 		c.t1.Remove(key, ele)
 		item := c.items[key]
 		if !item.IsExpired(nil) {
 			c.t2.PushFront(key)
-			if !onLoad {
-				c.stats.IncrHitCount()
-			}
-			return item.value, nil
+			return &item.cacheItem, true
 		} else {
+			c.keepStale(&item.cacheItem)
 			delete(c.items, key)
 			c.b1.PushFront(key)
-			if c.evictedFunc != nil {
-				c.evictedFunc(item.key, item.value)
-			}
+			c.removed(&item.cacheItem, RemovalExpired)
 		}
 	}
 	if ele := c.t2.Get(key); ele != nil {
 		item := c.items[key]
 		if !item.IsExpired(nil) {
 			c.t2.MoveToFront(ele)
-			if !onLoad {
-				c.stats.IncrHitCount()
-			}
-			return item.value, nil
+			return &item.cacheItem, true
 		} else {
+			c.keepStale(&item.cacheItem)
 			delete(c.items, key)
 			c.t2.Remove(key, ele)
 			c.b2.PushFront(key)
-			if c.evictedFunc != nil {
-				c.evictedFunc(item.key, item.value)
-			}
-		}
-	}
-	if !onLoad {
-		c.stats.IncrMissCount()
-	}
-	return nil, KeyNotFoundError
-}
-
-func (c *ARCCache) getWithLoader(key interface{}, isWait bool) (interface{}, error) {
-	if c.loaderExpireFunc == nil {
-		return nil, KeyNotFoundError
-	}
-	value, _, err := c.load(key, func(v interface{}, expiration *time.Duration, e error) (interface{}, error) {
-		if e != nil {
-			return nil, e
-		}
-		c.mu.Lock()
-		defer c.mu.Unlock()
-		item, err := c.set(key, v)
-		if err != nil {
-			return nil, err
-		}
-		if expiration != nil {
-			t := c.clock.Now().Add(*expiration)
-			item.(*arcItem).expiration = &t
-		}
-		return v, nil
-	}, isWait)
-	if err != nil {
-		return nil, err
-	}
-	return value, nil
-}
-
-func (c *ARCCache) Keys(checkExpired bool) []interface{} {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	keys := make([]interface{}, 0, len(c.items))
-	now := time.Now()
-	for k := range c.items {
-		if !checkExpired || c.has(k, &now) {
-			keys = append(keys, k)
+			c.removed(&item.cacheItem, RemovalExpired)
 		}
 	}
-	return keys
+	return nil, false
 }
 
-func (c *ARCCache) Len(checkExpired bool) int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	if !checkExpired {
-		return len(c.items)
-	}
-	length := 0
-	now := time.Now()
-	for k := range c.items {
-		if c.has(k, &now) {
-			length++
-		}
+// peek 返回 key 对应的元素 不会删除过期的元素 调用时需持有 mu
+func (c *ARCCache) peek(key interface{}) (*cacheItem, bool) {
+	item, ok := c.items[key]
+	if !ok {
+		return nil, false
 	}
-	return length
+	return &item.cacheItem, true
 }
 
-func (c *ARCCache) Has(key interface{}) bool {
-	c.mu.RLock()
-	defer c.mu.Unlock()
-	now := time.Now()
-	return c.has(key, &now)
-}
-
-func (c *ARCCache) has(key interface{}, now *time.Time) bool {
-	it, ok := c.items[key]
-	if !ok {
-		return false
+func (c *ARCCache) each(fn func(item *cacheItem)) {
+	for _, item := range c.items {
+		fn(&item.cacheItem)
 	}
-	return !it.IsExpired(now)
 }
 
-func (c *ARCCache) Remove(key interface{}) bool {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	return c.remove(key)
+func (c *ARCCache) length() int {
+	return len(c.items)
 }
 
-func (c *ARCCache) remove(key interface{}) bool {
+func (c *ARCCache) remove(key interface{}, cause RemovalCause) bool {
 	if elt := c.t1.Get(key); elt != nil {
 		c.t1.Remove(key, elt)
 		item := c.items[key]
 		delete(c.items, key)
 		c.b1.PushFront(key)
-		if c.evictedFunc != nil {
-			c.evictedFunc(key, item.value)
-		}
+		c.removed(&item.cacheItem, cause)
 		return true
 	}
 
@@ -296,9 +169,7 @@ func (c *ARCCache) remove(key interface{}) bool {
 		item := c.items[key]
 		delete(c.items, key)
 		c.b2.PushFront(key)
-		if c.evictedFunc != nil {
-			c.evictedFunc(key, item.value)
-		}
+		c.removed(&item.cacheItem, cause)
 		return true
 	}
 
@@ -315,8 +186,9 @@ func (c *ARCCache) isCacheFull() bool {
 	return (c.t1.Len() + c.t2.Len()) == c.size
 }
 
+// replace 缓存已满时先删除已经过期的元素 没有过期的元素时再淘汰 t1 或者 t2 的末端
 func (c *ARCCache) replace(key interface{}) {
-	if !c.isCacheFull() {
+	if !c.isCacheFull() || c.removeExpired() > 0 {
 		return
 	}
 	var old interface{}
@@ -333,8 +205,44 @@ func (c *ARCCache) replace(key interface{}) {
 	item, ok := c.items[old]
 	if ok {
 		delete(c.items, old)
-		if c.evictedFunc != nil {
-			c.evictedFunc(item.key, item.value)
+		c.removed(&item.cacheItem, RemovalSize)
+	}
+}
+
+// evictOverweight 按照 replace 的规则淘汰 protect 以外的元素
+// 直到总重量不超过 maxWeight
+func (c *ARCCache) evictOverweight(protect interface{}) {
+	if !c.overweight() {
+		return
+	}
+	c.removeExpired()
+	for c.overweight() {
+		var old interface{}
+		switch {
+		case c.t1.Len() > 0 && c.t1.Back() != protect && (c.t1.Len() > c.part || c.t2.Len() == 0 || c.t2.Back() == protect):
+			old = c.t1.RemoveTail()
+			c.b1.PushFront(old)
+		case c.t2.Len() > 0 && c.t2.Back() != protect:
+			old = c.t2.RemoveTail()
+			c.b2.PushFront(old)
+		case c.t1.Len() > 0 && c.t1.Back() != protect:
+			old = c.t1.RemoveTail()
+			c.b1.PushFront(old)
+		default:
+			return
+		}
+		// 幽灵链表的总长度不能超过 size
+		for c.b1.Len()+c.b2.Len() > c.size {
+			if c.b2.Len() > 0 {
+				c.b2.RemoveTail()
+			} else {
+				c.b1.RemoveTail()
+			}
+		}
+		item, ok := c.items[old]
+		if ok {
+			delete(c.items, old)
+			c.removed(&item.cacheItem, RemovalSize)
 		}
 	}
 }
@@ -388,24 +296,18 @@ func (a *arcList) RemoveTail() interface{} {
 	return key
 }
 
+// Back 返回链表末端的 key
+func (a *arcList) Back() interface{} {
+	if ele := a.l.Back(); ele != nil {
+		return ele.Value
+	}
+	return nil
+}
+
 func (a *arcList) Len() int {
 	return a.l.Len()
 }
 
 type arcItem struct {
-	clock      Clock
-	key        interface{}
-	value      interface{}
-	expiration *time.Time
-}
-
-func (it *arcItem) IsExpired(now *time.Time) bool {
-	if it.expiration == nil {
-		return false
-	}
-	if now == nil {
-		t := it.clock.Now()
-		now = &t
-	}
-	return it.expiration.Before(*now)
+	cacheItem
 }
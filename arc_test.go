@@ -55,6 +55,66 @@ func TestARCGetIFPresent(t *testing.T) {
 	testGetIFPresent(t, TypeArc)
 }
 
+func TestARCGetCtx(t *testing.T) {
+	testGetCtx(t, TypeArc)
+}
+
+func TestARCGetMany(t *testing.T) {
+	testGetMany(t, TypeArc)
+}
+
+func TestARCCompute(t *testing.T) {
+	testCompute(t, TypeArc)
+}
+
+func TestARCRefreshAfterWrite(t *testing.T) {
+	testRefreshAfterWrite(t, TypeArc)
+}
+
+func TestARCStaleIfError(t *testing.T) {
+	testStaleIfError(t, TypeArc)
+}
+
+func TestARCNegativeTTL(t *testing.T) {
+	testNegativeTTL(t, TypeArc)
+}
+
+func TestARCWeigher(t *testing.T) {
+	testWeigher(t, TypeArc)
+}
+
+func TestARCExpireAfterAccess(t *testing.T) {
+	testExpireAfterAccess(t, TypeArc)
+}
+
+func TestARCExpiry(t *testing.T) {
+	testExpiry(t, TypeArc)
+}
+
+func TestARCCleanupInterval(t *testing.T) {
+	testCleanupInterval(t, TypeArc)
+}
+
+func TestARCEvictExpiredFirst(t *testing.T) {
+	testEvictExpiredFirst(t, TypeArc)
+}
+
+func TestARCRemovalListener(t *testing.T) {
+	testRemovalListener(t, TypeArc)
+}
+
+func TestARCAsyncListeners(t *testing.T) {
+	testAsyncListeners(t, TypeArc)
+}
+
+func TestARCPurge(t *testing.T) {
+	testPurge(t, TypeArc)
+}
+
+func TestARCStats(t *testing.T) {
+	testStats(t, TypeArc)
+}
+
 func TestARCHas(t *testing.T) {
 	gc := buildTestLoadingCacheWithExpiration(t, TypeArc, 2, 10*time.Millisecond)
 
@@ -5,6 +5,7 @@ cache 模块负责提供缓存的并发控制
 */
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -16,6 +17,18 @@ const (
 	TypeLru    = "lru"
 	TypeLfu    = "lfu"
 	TypeArc    = "arc"
+	// W-TinyLFU 适合访问频率偏斜并且夹杂着扫描的负载
+	TypeWTinyLFU = "wtinylfu"
+	TypeTwoQueue = "2q"
+	// S3-FIFO 命中时只需要读锁
+	TypeS3FIFO = "s3fifo"
+	// CLOCK 和 CLOCK-Pro 命中时只需要设置引用位
+	TypeClock    = "clock"
+	TypeClockPro = "clockpro"
+	// LIRS 适合循环访问比缓存更大的范围的负载
+	TypeLIRS = "lirs"
+	// GDSF 淘汰时考虑元素的代价和重量
+	TypeGDSF = "gdsf"
 )
 
 var KeyNotFoundError = errors.New("key not found")
@@ -23,68 +36,644 @@ var KeyNotFoundError = errors.New("key not found")
 type Cache interface {
 	Set(key, value interface{}) error
 	SetWithExpire(key, value interface{}, expiration time.Duration) error
+	SetWithCost(key, value interface{}, cost float64) error
+	SetWithExpireAfterAccess(key, value interface{}, idle time.Duration) error
+	SetWithExpireAfterAccessAndLifetime(key, value interface{}, idle, lifetime time.Duration) error
 	Get(key interface{}) (interface{}, error)
+	GetCtx(ctx context.Context, key interface{}) (interface{}, error)
 	GetALL(checkExpired bool) map[interface{}]interface{}
 	GetIfPresent(key interface{}) (interface{}, error)
 	get(key interface{}, onLoad bool) (interface{}, error)
+	GetMany(keys []interface{}) (map[interface{}]interface{}, error)
+	SetMany(items map[interface{}]interface{}) error
+	GetOrSet(key, value interface{}) (interface{}, bool, error)
+	Compute(key interface{}, fn ComputeFunc) (interface{}, error)
+	CompareAndSwap(key, old, new interface{}) bool
+	Incr(key interface{}, delta int64) (int64, error)
+	IncrFrom(key interface{}, delta int64, initial interface{}) (int64, error)
+	Decr(key interface{}, delta int64) (int64, error)
 	Keys(checkExpired bool) []interface{}
 	Len(checkExpired bool) int
 	Has(key interface{}) bool
 	Remove(key interface{}) bool
+	Purge()
+	Close()
 	statsAccessor
 }
 
 type (
 	LoaderFunc       func(interface{}) (interface{}, error)
 	LoaderExpireFunc func(interface{}) (interface{}, *time.Duration, error)
-	EvictedFunc      func(interface{}, interface{})
-	AddedFunc        func(interface{}, interface{})
+	// 可以感知 ctx 的加载器 ctx 在所有等待者都放弃之后才会被取消
+	LoaderCtxFunc       func(context.Context, interface{}) (interface{}, error)
+	LoaderExpireCtxFunc func(context.Context, interface{}) (interface{}, *time.Duration, error)
+	// 批量加载器 返回的 map 中没有的 key 视为不存在
+	BatchLoaderFunc func([]interface{}) (map[interface{}]interface{}, error)
+	EvictedFunc     func(interface{}, interface{})
+	AddedFunc       func(interface{}, interface{})
+	// 清空缓存时对每个未过期的元素调用
+	PurgeVisitorFunc func(interface{}, interface{})
 )
 
 type baseCache struct {
-	clock            Clock            // 时间接口
-	size             int              // 缓存容量
-	loaderExpireFunc LoaderExpireFunc // 带有过期时间的加载器函数
-	evictedFunc      EvictedFunc      // 元素被清理时触发的回调函数
-	addedFunc        AddedFunc        // 元素被添加时触发的回调函数
-	expiration       *time.Duration   // 过期时间
-	mu               sync.RWMutex     // 读写锁
-	group            Group            // singleFlight
+	clock             Clock                      // 时间接口
+	size              int                        // 缓存容量
+	loaderExpireFunc  LoaderExpireCtxFunc        // 带有过期时间的加载器函数
+	batchLoaderFunc   BatchLoaderFunc            // 批量加载器函数
+	evictedFunc       EvictedFunc                // 元素被清理时触发的回调函数
+	removalListener   RemovalListener            // 元素被删除或者值被覆盖时触发的回调函数
+	purgeVisitorFunc  PurgeVisitorFunc           // 清空缓存时对每个未过期的元素调用
+	addedFunc         AddedFunc                  // 元素被添加时触发的回调函数
+	expiration        *time.Duration             // 过期时间
+	expireAfterAccess *time.Duration             // 多久没有被访问之后过期
+	expiry            Expiry                     // 由元素决定的过期时间
+	refreshAfterWrite *time.Duration             // 写入多久之后在后台刷新
+	staleIfError      *time.Duration             // 过期之后还能在加载失败时使用多久
+	stale             map[interface{}]*cacheItem // 过期之后保留下来的元素
+	negativeTTL       *time.Duration             // 加载失败的错误缓存多久
+	negatives         map[interface{}]*negativeEntry
+	weigher           Weigher      // 计算元素重量的函数
+	maxWeight         int64        // 最大总重量 0 表示不限制
+	maxItemWeight     int64        // 单个元素的最大重量 0 表示与 maxWeight 相同
+	weight            int64        // 当前的总重量
+	mu                sync.RWMutex // 读写锁
+	group             Group        // singleFlight
+	store             itemStore    // 具体的淘汰策略
+	timers            *expiryQueue // 按照过期时间排列的元素
+	janitor           *janitor     // 后台清理
+	dispatcher        *dispatcher  // 异步调用监听函数
+	workers           []*worker    // 持有 mu 时放入了事件的 worker
+	events            []event      // dispatcher 关闭之后持有 mu 时产生的事件
+	keepTTL           bool         // 读-改-写操作保留已经存在的元素的过期时间
 	*stats
 }
 
-func (c *baseCache) load(key interface{}, cb func(interface{}, *time.Duration, error) (interface{}, error), isWait bool) (interface{}, bool, error) {
-	v, called, err := c.group.Do(key, func() (v interface{}, e error) {
+// load 通过 singleflight 调用加载器 cb 收到加载器的结果以及加载的耗时
+func (c *baseCache) load(ctx context.Context, key interface{}, cb func(interface{}, *time.Duration, time.Duration, error) (interface{}, error), isWait bool) (interface{}, bool, error) {
+	if err, ok := c.cachedErr(key); ok {
+		v, err := c.serveStale(key, err)
+		return v, false, err
+	}
+	v, called, err := c.group.DoCtx(ctx, key, func(ctx context.Context) (v interface{}, e error) {
+		start := time.Now()
 		defer func() {
 			if r := recover(); r != nil {
 				e = fmt.Errorf("loader panics: %v", r)
 			}
+			c.stats.RecordLoad(time.Since(start), e)
 		}()
-		return cb(c.loaderExpireFunc(key))
+		v, expiration, e := c.loaderExpireFunc(ctx, key)
+		return cb(v, expiration, time.Since(start), e)
 	}, isWait)
 	if err != nil {
-		return nil, called, err
+		if isWait && c.negativeTTL != nil {
+			c.mu.Lock()
+			c.keepNegative(key, err)
+			c.unlock()
+		}
+		v, err = c.serveStale(key, err)
+		return v, called, err
 	}
 	return v, called, nil
 }
 
+func (c *baseCache) newItem(key, value interface{}) cacheItem {
+	return cacheItem{
+		clock:  c.clock,
+		key:    key,
+		value:  value,
+		cost:   1,
+		timers: c.timers,
+	}
+}
+
+// written 记录元素被写入的时间 并设置默认的过期时间
+// 设置了 Expiry 时使用 Expiry 计算过期时间
+func (c *baseCache) written(item *cacheItem) {
+	now := c.clock.Now()
+	created := !item.written
+	item.written = true
+	item.writeTime = now
+	delete(c.stale, item.key)
+	delete(c.negatives, item.key)
+	if c.expiry != nil {
+		c.expireAfterWrite(item, created, now)
+		return
+	}
+	if c.keepTTL && !created {
+		// 写入视为一次访问 只重新计算按照访问时间的过期时间
+		item.touch(now)
+		return
+	}
+	// 通过 Set 写入时重新按照 CacheBuilder 的设置计算过期时间 不保留之前单独设置的过期时间
+	item.idle = c.expireAfterAccess
+	if c.expiration != nil {
+		item.expireAt(now.Add(*c.expiration), now)
+	} else {
+		item.deadline = nil
+		item.touch(now)
+	}
+}
+
+// removed 在元素被删除之后调用 调用时需持有 mu
+func (c *baseCache) removed(item *cacheItem, cause RemovalCause) {
+	c.timers.remove(item)
+	c.stats.IncrRemovalCount(cause)
+	c.weight -= item.weight
+	if c.evictedFunc != nil || c.removalListener != nil {
+		c.notify(event{kind: eventRemoved, key: item.key, value: item.value, cause: cause})
+	}
+}
+
+func (c *baseCache) Set(key, value interface{}) error {
+	c.mu.Lock()
+	defer c.unlock()
+	_, err := c.store.set(key, value)
+	return err
+}
+
+func (c *baseCache) SetWithExpire(key, value interface{}, expiration time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock()
+	item, err := c.store.set(key, value)
+	if err != nil || item == nil {
+		return err
+	}
+	now := c.clock.Now()
+	item.expireAt(now.Add(expiration), now)
+	return nil
+}
+
+// SetWithExpireAfterAccess 元素在 idle 时间内没有被访问就会过期 每次命中都会重新计时
+// 同时设置了 Expiration 时 过期时间不会晚于写入之后的 Expiration
+func (c *baseCache) SetWithExpireAfterAccess(key, value interface{}, idle time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock()
+	item, err := c.store.set(key, value)
+	if err != nil || item == nil {
+		return err
+	}
+	item.idle = &idle
+	item.touch(c.clock.Now())
+	return nil
+}
+
+// SetWithExpireAfterAccessAndLifetime 与 SetWithExpireAfterAccess 相同 同时元素最多存活 lifetime
+func (c *baseCache) SetWithExpireAfterAccessAndLifetime(key, value interface{}, idle, lifetime time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock()
+	item, err := c.store.set(key, value)
+	if err != nil || item == nil {
+		return err
+	}
+	now := c.clock.Now()
+	item.idle = &idle
+	item.expireAt(now.Add(lifetime), now)
+	return nil
+}
+
+func (c *baseCache) SetMany(items map[interface{}]interface{}) error {
+	c.mu.Lock()
+	defer c.unlock()
+	for k, v := range items {
+		if _, err := c.store.set(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setMany 与 SetMany 相同 同时设置每个元素的代价
+func (c *baseCache) setMany(items map[interface{}]interface{}, cost float64) error {
+	c.mu.Lock()
+	defer c.unlock()
+	for k, v := range items {
+		item, err := c.store.set(k, v)
+		if err != nil {
+			return err
+		}
+		if item != nil {
+			c.setCost(item, cost)
+		}
+	}
+	return nil
+}
+
+func (c *baseCache) Get(key interface{}) (interface{}, error) {
+	return c.GetCtx(context.Background(), key)
+}
+
+func (c *baseCache) GetCtx(ctx context.Context, key interface{}) (interface{}, error) {
+	v, err := c.get(key, false)
+	if err == KeyNotFoundError {
+		return c.getWithLoader(ctx, key, true)
+	}
+	return v, err
+}
+
+func (c *baseCache) GetIfPresent(key interface{}) (interface{}, error) {
+	v, err := c.get(key, false)
+	if err == KeyNotFoundError {
+		return c.getWithLoader(context.Background(), key, false)
+	}
+	return v, err
+}
+
+// GetMany 在一次加锁中查找所有 key 未命中的 key 会被一起加载
+func (c *baseCache) GetMany(keys []interface{}) (map[interface{}]interface{}, error) {
+	items, misses := c.lookupMany(keys)
+	return c.loadMany(context.Background(), items, misses)
+}
+
+func (c *baseCache) get(key interface{}, onLoad bool) (interface{}, error) {
+	return c.getValue(key, onLoad)
+}
+
+func (c *baseCache) getWithLoader(ctx context.Context, key interface{}, isWait bool) (interface{}, error) {
+	if c.loaderExpireFunc == nil {
+		return nil, KeyNotFoundError
+	}
+	value, _, err := c.load(ctx, key, func(v interface{}, expiration *time.Duration, elapsed time.Duration, e error) (interface{}, error) {
+		if e != nil {
+			return nil, e
+		}
+		c.mu.Lock()
+		defer c.unlock()
+		item, err := c.store.set(key, v)
+		if err != nil {
+			return nil, err
+		}
+		if item == nil {
+			return v, nil
+		}
+		c.setCost(item, loadCost(elapsed))
+		if expiration != nil {
+			now := c.clock.Now()
+			item.expireAt(now.Add(*expiration), now)
+		}
+		return v, nil
+	}, isWait)
+	return value, err
+}
+
+func (c *baseCache) GetALL(checkExpired bool) map[interface{}]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	items := make(map[interface{}]interface{}, c.store.length())
+	now := c.clock.Now()
+	c.store.each(func(item *cacheItem) {
+		if !checkExpired || !item.IsExpired(&now) {
+			items[item.key] = item.value
+		}
+	})
+	return items
+}
+
+func (c *baseCache) Keys(checkExpired bool) []interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys := make([]interface{}, 0, c.store.length())
+	now := c.clock.Now()
+	c.store.each(func(item *cacheItem) {
+		if !checkExpired || !item.IsExpired(&now) {
+			keys = append(keys, item.key)
+		}
+	})
+	return keys
+}
+
+func (c *baseCache) Len(checkExpired bool) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !checkExpired {
+		return c.store.length()
+	}
+	length := 0
+	now := c.clock.Now()
+	c.store.each(func(item *cacheItem) {
+		if !item.IsExpired(&now) {
+			length++
+		}
+	})
+	return length
+}
+
+func (c *baseCache) Has(key interface{}) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	item, ok := c.store.peek(key)
+	if !ok {
+		return false
+	}
+	now := c.clock.Now()
+	return !item.IsExpired(&now)
+}
+
+func (c *baseCache) Remove(key interface{}) bool {
+	c.mu.Lock()
+	defer c.unlock()
+	delete(c.stale, key)
+	delete(c.negatives, key)
+	return c.store.remove(key, RemovalExplicit)
+}
+
+// Purge 在一次加锁中清空所有的元素并重置内部结构 统计数据会被保留
+// PurgeVisitorFunc 在释放锁之后调用 因此可以在其中访问缓存
+func (c *baseCache) Purge() {
+	c.mu.Lock()
+	var live []*cacheItem
+	now := c.clock.Now()
+	c.store.each(func(item *cacheItem) {
+		cause := RemovalCleared
+		if item.IsExpired(&now) {
+			cause = RemovalExpired
+		} else if c.purgeVisitorFunc != nil {
+			live = append(live, item)
+		}
+		c.stats.IncrRemovalCount(cause)
+		if c.evictedFunc != nil || c.removalListener != nil {
+			c.notify(event{kind: eventRemoved, key: item.key, value: item.value, cause: cause})
+		}
+	})
+	c.store.init()
+	c.weight = 0
+	c.timers = &expiryQueue{}
+	c.stale = nil
+	c.negatives = nil
+	c.unlock()
+	for _, item := range live {
+		c.purgeVisitorFunc(item.key, item.value)
+	}
+}
+
+func (c *baseCache) getValue(key interface{}, onLoad bool) (interface{}, error) {
+	v, writeTime, ok := c.lookupValue(key)
+	if !ok {
+		if !onLoad {
+			c.stats.IncrMissCount()
+		}
+		return nil, KeyNotFoundError
+	}
+	if !onLoad {
+		c.stats.IncrHitCount()
+		c.refresh(key, writeTime)
+	}
+	return v, nil
+}
+
+// lookup 查找未过期的元素 并且推迟按照访问时间过期的元素的过期时间
+// 调用时需持有 mu
+func (c *baseCache) lookup(key interface{}) (*cacheItem, bool) {
+	item, ok := c.store.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	if c.expiry != nil {
+		c.expireAfterRead(item, c.clock.Now())
+	} else if item.idle != nil {
+		item.touch(c.clock.Now())
+	}
+	return item, true
+}
+
+// lookupValue 查找 key 对应的值以及写入的时间
+// 策略支持时先只持有读锁查找 未命中时再持有写锁查找
+func (c *baseCache) lookupValue(key interface{}) (v interface{}, writeTime time.Time, ok bool) {
+	if s, shared := c.store.(sharedStore); shared {
+		c.mu.RLock()
+		item, ok := s.lookupShared(key)
+		if ok {
+			v, writeTime = item.value, item.writeTime
+		}
+		c.mu.RUnlock()
+		if ok {
+			return v, writeTime, true
+		}
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	item, ok := c.lookup(key)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	return item.value, item.writeTime, true
+}
+
+// refresh 元素写入超过 refreshAfterWrite 之后在后台重新加载
+// 调用时不能持有 mu
+func (c *baseCache) refresh(key interface{}, writeTime time.Time) {
+	if c.refreshAfterWrite == nil || c.loaderExpireFunc == nil {
+		return
+	}
+	if c.clock.Now().Sub(writeTime) < *c.refreshAfterWrite {
+		return
+	}
+	c.group.Refresh(key, func(ctx context.Context) (v interface{}, e error) {
+		start := time.Now()
+		defer func() {
+			if r := recover(); r != nil {
+				e = fmt.Errorf("loader panics: %v", r)
+			}
+			c.stats.RecordLoad(time.Since(start), e)
+		}()
+		v, expiration, e := c.loaderExpireFunc(ctx, key)
+		if e != nil {
+			// 加载失败时保留旧值
+			return nil, e
+		}
+		c.mu.Lock()
+		defer c.unlock()
+		// 刷新期间元素被删除或者被重新写入 就不再覆盖
+		if item, ok := c.store.peek(key); !ok || !item.writeTime.Equal(writeTime) {
+			return v, nil
+		}
+		item, e := c.update(key, v)
+		if e != nil {
+			return nil, e
+		}
+		if item != nil && expiration != nil {
+			now := c.clock.Now()
+			item.expireAt(now.Add(*expiration), now)
+		}
+		return v, nil
+	})
+}
+
+// lookupMany 在一次加锁中依次查找 keys 返回命中的元素和未命中的 key
+func (c *baseCache) lookupMany(keys []interface{}) (map[interface{}]interface{}, []interface{}) {
+	items := make(map[interface{}]interface{}, len(keys))
+	writeTimes := make(map[interface{}]time.Time, len(keys))
+	var misses []interface{}
+	c.mu.Lock()
+	for _, key := range keys {
+		if _, ok := items[key]; ok {
+			continue
+		}
+		if _, ok := writeTimes[key]; ok {
+			continue
+		}
+		if item, ok := c.lookup(key); ok {
+			items[key] = item.value
+			writeTimes[key] = item.writeTime
+		} else {
+			// 未命中的 key 也记录下来用于去重
+			writeTimes[key] = time.Time{}
+			misses = append(misses, key)
+		}
+	}
+	c.unlock()
+	for key := range items {
+		c.stats.IncrHitCount()
+		c.refresh(key, writeTimes[key])
+	}
+	for range misses {
+		c.stats.IncrMissCount()
+	}
+	return items, misses
+}
+
+// loadMany 加载 misses 中的 key 并把结果放入 items
+// 设置了 batchLoaderFunc 时所有 key 只会在一次调用中加载 否则逐个使用 loader 加载
+func (c *baseCache) loadMany(ctx context.Context, items map[interface{}]interface{}, misses []interface{}) (map[interface{}]interface{}, error) {
+	if len(misses) == 0 {
+		return items, nil
+	}
+	if c.batchLoaderFunc == nil {
+		if c.loaderExpireFunc == nil {
+			return items, nil
+		}
+		var staleErr error
+		for _, key := range misses {
+			v, err := c.getWithLoader(ctx, key, true)
+			if err == KeyNotFoundError {
+				continue
+			}
+			if err != nil {
+				if _, ok := err.(*StaleError); !ok {
+					return items, err
+				}
+				staleErr = err
+			}
+			items[key] = v
+		}
+		return items, staleErr
+	}
+	if c.negativeTTL != nil {
+		misses = c.filterNegatives(misses)
+	}
+	values, err := c.group.DoMany(ctx, misses, func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+		m, elapsed, e := c.batchLoad(ctx, keys)
+		if e != nil {
+			return nil, e
+		}
+		// 批量加载的耗时平均分配给每个元素
+		cost := loadCost(elapsed) / float64(max(len(m), 1))
+		if e = c.setMany(m, cost); e != nil {
+			return nil, e
+		}
+		return m, nil
+	})
+	for k, v := range values {
+		items[k] = v
+	}
+	if c.negativeTTL != nil {
+		c.mu.Lock()
+		for _, key := range misses {
+			if _, ok := values[key]; ok {
+				continue
+			}
+			if err != nil {
+				c.keepNegative(key, err)
+			} else {
+				c.keepNegative(key, KeyNotFoundError)
+			}
+		}
+		c.unlock()
+	}
+	if err != nil && c.staleIfError != nil {
+		served := true
+		for _, key := range misses {
+			if _, ok := items[key]; ok {
+				continue
+			}
+			v, e := c.serveStale(key, err)
+			if _, ok := e.(*StaleError); !ok {
+				served = false
+				continue
+			}
+			items[key] = v
+		}
+		if served {
+			err = &StaleError{Err: err}
+		}
+	}
+	return items, err
+}
+
+// batchLoad 加载 keys 返回加载的结果和耗时
+// ctx 中带有 shardedCache 的 batchMember 时和其他分片未命中的 key 合并成一次调用
+func (c *baseCache) batchLoad(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, time.Duration, error) {
+	if member, ok := ctx.Value(batchMemberKey{}).(*batchMember); ok {
+		return member.load(keys)
+	}
+	return c.callBatchLoader(keys)
+}
+
+// callBatchLoader 调用一次 batchLoaderFunc 并记录加载的统计数据
+func (c *baseCache) callBatchLoader(keys []interface{}) (m map[interface{}]interface{}, elapsed time.Duration, e error) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			m, e = nil, fmt.Errorf("loader panics: %v", r)
+		}
+		elapsed = time.Since(start)
+		c.stats.RecordLoad(elapsed, e)
+	}()
+	m, e = c.batchLoaderFunc(keys)
+	return
+}
+
 // 使用建造者模式
 
 type CacheBuilder struct {
-	clock            Clock
-	tp               string
-	size             int
-	loaderExpireFunc LoaderExpireFunc
-	evictedFunc      EvictedFunc
-	addedFunc        AddedFunc
-	expiration       *time.Duration
+	clock             Clock
+	tp                string
+	size              int
+	loaderExpireFunc  LoaderExpireCtxFunc
+	batchLoaderFunc   BatchLoaderFunc
+	evictedFunc       EvictedFunc
+	removalListener   RemovalListener
+	purgeVisitorFunc  PurgeVisitorFunc
+	addedFunc         AddedFunc
+	expiration        *time.Duration
+	expireAfterAccess *time.Duration
+	expiry            Expiry
+	refreshAfterWrite *time.Duration
+	staleIfError      *time.Duration
+	negativeTTL       *time.Duration
+	weigher           Weigher
+	maxWeight         int64
+	shards            int
+	keyHasher         KeyHasher
+	recentRatio       float64
+	ghostRatio        float64
+	hirRatio          float64
+	frequencyDecay    *time.Duration
+	cleanupInterval   *time.Duration
+	asyncQueueSize    int
+	asyncFullPolicy   FullPolicy
+	dispatcher        *dispatcher // 分片共享的 dispatcher
+	maxItemWeight     int64       // 分片中单个元素的最大重量
 }
 
 func New(size int) *CacheBuilder {
 	return &CacheBuilder{
-		clock: NewRealClock(),
-		tp:    TypeSimple,
-		size:  size,
+		clock:       NewRealClock(),
+		tp:          TypeSimple,
+		size:        size,
+		recentRatio: DefaultTwoQueueRecentRatio,
+		ghostRatio:  DefaultTwoQueueGhostRatio,
+		hirRatio:    DefaultHIRRatio,
 	}
 }
 
@@ -114,9 +703,52 @@ func (c *CacheBuilder) ARC() *CacheBuilder {
 	return c.EvictType(TypeArc)
 }
 
+func (c *CacheBuilder) WTinyLFU() *CacheBuilder {
+	return c.EvictType(TypeWTinyLFU)
+}
+
+func (c *CacheBuilder) TwoQueue() *CacheBuilder {
+	return c.EvictType(TypeTwoQueue)
+}
+
+// TwoQueueRatios 设置 2Q 中 A1in 占容量的比例 以及 A1out 能记录的 key 的数量与容量的比例
+// 默认为 DefaultTwoQueueRecentRatio 和 DefaultTwoQueueGhostRatio
+func (c *CacheBuilder) TwoQueueRatios(recentRatio, ghostRatio float64) *CacheBuilder {
+	c.recentRatio = recentRatio
+	c.ghostRatio = ghostRatio
+	return c
+}
+
+func (c *CacheBuilder) S3FIFO() *CacheBuilder {
+	return c.EvictType(TypeS3FIFO)
+}
+
+func (c *CacheBuilder) GDSF() *CacheBuilder {
+	return c.EvictType(TypeGDSF)
+}
+
+// HIRRatio 设置 LIRS 中驻留的 HIR 元素占容量的比例 默认为 DefaultHIRRatio
+func (c *CacheBuilder) HIRRatio(ratio float64) *CacheBuilder {
+	c.hirRatio = ratio
+	return c
+}
+
+// FrequencyDecay LFU 中所有元素的访问频率每经过 interval 减半
+// 过去的热点不会一直留在缓存中
+func (c *CacheBuilder) FrequencyDecay(interval time.Duration) *CacheBuilder {
+	c.frequencyDecay = &interval
+	return c
+}
+
+// CleanupInterval 每隔 interval 在后台删除已经过期的元素 不再使用时需要调用 Close
+func (c *CacheBuilder) CleanupInterval(interval time.Duration) *CacheBuilder {
+	c.cleanupInterval = &interval
+	return c
+}
+
 // LoaderFunc 当一个元素把另一个元素挤出缓存的时候 调用该函数
 func (c *CacheBuilder) LoaderFunc(loaderFunc LoaderFunc) *CacheBuilder {
-	c.loaderExpireFunc = func(k interface{}) (interface{}, *time.Duration, error) {
+	c.loaderExpireFunc = func(_ context.Context, k interface{}) (interface{}, *time.Duration, error) {
 		v, err := loaderFunc(k)
 		return v, nil, err
 	}
@@ -124,15 +756,57 @@ func (c *CacheBuilder) LoaderFunc(loaderFunc LoaderFunc) *CacheBuilder {
 }
 
 func (c *CacheBuilder) LoaderExpireFunc(expireFunc LoaderExpireFunc) *CacheBuilder {
+	c.loaderExpireFunc = func(_ context.Context, k interface{}) (interface{}, *time.Duration, error) {
+		return expireFunc(k)
+	}
+	return c
+}
+
+// LoaderCtxFunc 与 LoaderFunc 相同 但加载器可以通过 ctx 感知取消和超时
+func (c *CacheBuilder) LoaderCtxFunc(loaderFunc LoaderCtxFunc) *CacheBuilder {
+	c.loaderExpireFunc = func(ctx context.Context, k interface{}) (interface{}, *time.Duration, error) {
+		v, err := loaderFunc(ctx, k)
+		return v, nil, err
+	}
+	return c
+}
+
+func (c *CacheBuilder) LoaderExpireCtxFunc(expireFunc LoaderExpireCtxFunc) *CacheBuilder {
 	c.loaderExpireFunc = expireFunc
 	return c
 }
 
+// BatchLoaderFunc GetMany 未命中的 key 会通过一次调用批量加载
+func (c *CacheBuilder) BatchLoaderFunc(batchLoaderFunc BatchLoaderFunc) *CacheBuilder {
+	c.batchLoaderFunc = batchLoaderFunc
+	return c
+}
+
 func (c *CacheBuilder) EvictedFunc(evictedFunc EvictedFunc) *CacheBuilder {
 	c.evictedFunc = evictedFunc
 	return c
 }
 
+// PurgeVisitorFunc Purge 清空缓存之后 对每个被清空的未过期的元素调用 visitor
+func (c *CacheBuilder) PurgeVisitorFunc(visitor PurgeVisitorFunc) *CacheBuilder {
+	c.purgeVisitorFunc = visitor
+	return c
+}
+
+// AsyncListeners 在锁外异步调用 AddedFunc EvictedFunc 和 RemovalListener
+// 最多缓存 queueSize 个事件 队列满了之后按照 full 处理 不再使用时需要调用 Close
+func (c *CacheBuilder) AsyncListeners(queueSize int, full FullPolicy) *CacheBuilder {
+	c.asyncQueueSize = queueSize
+	c.asyncFullPolicy = full
+	return c
+}
+
+// RemovalListener 元素被删除或者值被覆盖时调用 listener 可以通过 cause 区分原因
+func (c *CacheBuilder) RemovalListener(listener RemovalListener) *CacheBuilder {
+	c.removalListener = listener
+	return c
+}
+
 func (c *CacheBuilder) AddedFunc(addedFunc AddedFunc) *CacheBuilder {
 	c.addedFunc = addedFunc
 	return c
@@ -143,14 +817,89 @@ func (c *CacheBuilder) Expiration(expiration time.Duration) *CacheBuilder {
 	return c
 }
 
+// ExpireAfterAccess 元素在 d 时间内没有被访问就会过期 每次命中都会重新计时
+// 与 Expiration 一起使用时 Expiration 是元素最长的存活时间
+func (c *CacheBuilder) ExpireAfterAccess(d time.Duration) *CacheBuilder {
+	c.expireAfterAccess = &d
+	return c
+}
+
+// Expiry 由 expiry 决定每个元素的过期时间 设置之后 Expiration 和 ExpireAfterAccess 不再生效
+func (c *CacheBuilder) Expiry(expiry Expiry) *CacheBuilder {
+	c.expiry = expiry
+	return c
+}
+
+// RefreshAfterWrite 元素写入超过 d 之后 下一次 Get 会直接返回当前的值
+// 同时在后台重新加载 加载失败时保留旧值
+func (c *CacheBuilder) RefreshAfterWrite(d time.Duration) *CacheBuilder {
+	c.refreshAfterWrite = &d
+	return c
+}
+
+// StaleIfError 过期的元素会再保留 maxStale
+// 期间加载器返回错误时 会返回这个过期的值以及 StaleError
+func (c *CacheBuilder) StaleIfError(maxStale time.Duration) *CacheBuilder {
+	c.staleIfError = &maxStale
+	return c
+}
+
+// NegativeTTL 加载器返回的错误会被缓存 d
+// 在此期间 Get 直接返回缓存的错误 不会再次调用加载器
+// 加载器返回 KeyNotFoundError 表示 key 不存在
+func (c *CacheBuilder) NegativeTTL(d time.Duration) *CacheBuilder {
+	c.negativeTTL = &d
+	return c
+}
+
+// Weigher 设置计算元素重量的函数 与 MaxWeight 一起使用
+func (c *CacheBuilder) Weigher(weigher Weigher) *CacheBuilder {
+	c.weigher = weigher
+	return c
+}
+
+// MaxWeight 元素的总重量超过 n 时进行淘汰
+// 比 n 还重的元素无法放入缓存 Set 会返回 ItemTooHeavyError
+func (c *CacheBuilder) MaxWeight(n int64) *CacheBuilder {
+	c.maxWeight = n
+	return c
+}
+
+// Shards 把 key 分散到 n 个相互独立的缓存中 容量和 MaxWeight 平均分配到每个分片 总和与设置的相同
+// n 大于容量或者 MaxWeight 时分片的个数减少到容量或者 MaxWeight
+// 元素是否太重仍然按照整个缓存的 MaxWeight 判断 比分片的最大重量还重的元素会独占这个分片
+// 所有分片共享 AsyncListeners 的队列和 CleanupInterval 的后台清理 GetMany 只调用一次 BatchLoaderFunc
+func (c *CacheBuilder) Shards(n int) *CacheBuilder {
+	c.shards = n
+	return c
+}
+
+// KeyHasher 设置分片使用的哈希函数 默认使用 maphash
+func (c *CacheBuilder) KeyHasher(hasher KeyHasher) *CacheBuilder {
+	c.keyHasher = hasher
+	return c
+}
+
 func (c *CacheBuilder) Build() Cache {
 	if c.size <= 0 && c.tp != TypeSimple {
 		panic("cache size <= 0")
 	}
+	if c.recentRatio < 0 || c.recentRatio > 1 || c.ghostRatio < 0 || c.ghostRatio > 1 {
+		panic("2Q ratios must be between 0 and 1")
+	}
+	if c.hirRatio < 0 || c.hirRatio > 1 {
+		panic("HIR ratio must be between 0 and 1")
+	}
+	if c.cleanupInterval != nil && *c.cleanupInterval <= 0 {
+		panic("cleanup interval must be positive")
+	}
 	return c.build()
 }
 
 func (c *CacheBuilder) build() Cache {
+	if c.shards > 1 {
+		return newShardedCache(c)
+	}
 	switch c.tp {
 	case TypeSimple:
 		return newSimpleCache(c)
@@ -161,16 +910,59 @@ func (c *CacheBuilder) build() Cache {
 	case TypeArc:
 		return newARCCache(c)
 	default:
+		if factory, ok := c.policyFactory(); ok {
+			return newPolicyCache(c, factory)
+		}
 		panic("Unknown type")
 	}
 }
 
+// policyFactory 返回基于 policyCache 实现的类型对应的淘汰策略
+func (c *CacheBuilder) policyFactory() (PolicyFactory, bool) {
+	switch c.tp {
+	case TypeWTinyLFU:
+		return func(size int) EvictionPolicy { return newWTinyLFU(size) }, true
+	case TypeTwoQueue:
+		recent, ghost := c.recentRatio, c.ghostRatio
+		return func(size int) EvictionPolicy { return newTwoQueue(size, recent, ghost) }, true
+	case TypeS3FIFO:
+		return func(size int) EvictionPolicy { return newS3FIFO(size) }, true
+	case TypeClock:
+		return func(size int) EvictionPolicy { return newClockPolicy(size) }, true
+	case TypeClockPro:
+		return func(size int) EvictionPolicy { return newClockPro(size) }, true
+	case TypeLIRS:
+		hirRatio := c.hirRatio
+		return func(size int) EvictionPolicy { return newLIRS(size, hirRatio) }, true
+	case TypeGDSF:
+		return func(size int) EvictionPolicy { return newGDSF(size) }, true
+	default:
+		return lookupPolicy(c.tp)
+	}
+}
+
 func buildCache(c *baseCache, cb *CacheBuilder) {
 	c.clock = cb.clock
 	c.size = cb.size
 	c.loaderExpireFunc = cb.loaderExpireFunc
+	c.batchLoaderFunc = cb.batchLoaderFunc
 	c.expiration = cb.expiration
+	c.expireAfterAccess = cb.expireAfterAccess
+	c.expiry = cb.expiry
+	c.refreshAfterWrite = cb.refreshAfterWrite
+	c.staleIfError = cb.staleIfError
+	c.negativeTTL = cb.negativeTTL
+	c.weigher = cb.weigher
+	c.maxWeight = cb.maxWeight
+	c.maxItemWeight = cb.maxItemWeight
 	c.evictedFunc = cb.evictedFunc
+	c.removalListener = cb.removalListener
+	c.purgeVisitorFunc = cb.purgeVisitorFunc
 	c.addedFunc = cb.addedFunc
 	c.stats = &stats{}
+	c.timers = &expiryQueue{}
+	c.dispatcher = cb.dispatcher
+	if c.dispatcher == nil && cb.asyncQueueSize > 0 {
+		c.dispatcher = newDispatcher(cb.asyncQueueSize, cb.asyncFullPolicy)
+	}
 }
@@ -0,0 +1,141 @@
+package hyliocache
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+/*
+cleanup 模块在后台定期删除已经过期的元素
+会过期的元素按照过期时间放在一个最小堆中 每次清理只需要查看堆顶 不需要遍历所有的元素
+清理的间隔由 Clock 计时 Clock 没有实现 TickerClock 时使用真实的时间
+*/
+
+// expiryQueue 是按照过期时间排列的最小堆 淘汰元素之前也通过它找到已经过期的元素
+type expiryQueue []*cacheItem
+
+func (q expiryQueue) Len() int { return len(q) }
+
+func (q expiryQueue) Less(i, j int) bool { return q[i].expiration.Before(*q[j].expiration) }
+
+func (q expiryQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].timer = i + 1
+	q[j].timer = j + 1
+}
+
+func (q *expiryQueue) Push(x interface{}) {
+	item := x.(*cacheItem)
+	item.timer = len(*q) + 1
+	*q = append(*q, item)
+}
+
+func (q *expiryQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	item.timer = 0
+	return item
+}
+
+// fix 在元素的过期时间改变之后调整它在堆中的位置
+func (q *expiryQueue) fix(item *cacheItem) {
+	if q == nil {
+		return
+	}
+	switch {
+	case item.expiration == nil:
+		q.remove(item)
+	case item.timer == 0:
+		heap.Push(q, item)
+	default:
+		heap.Fix(q, item.timer-1)
+	}
+}
+
+func (q *expiryQueue) remove(item *cacheItem) {
+	if q == nil || item.timer == 0 {
+		return
+	}
+	heap.Remove(q, item.timer-1)
+}
+
+// peek 返回最早过期的元素
+func (q *expiryQueue) peek() *cacheItem {
+	if q == nil || len(*q) == 0 {
+		return nil
+	}
+	return (*q)[0]
+}
+
+type janitor struct {
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// startJanitor 开启后台清理 每个构造函数在设置了 store 之后调用
+func (c *baseCache) startJanitor(interval *time.Duration) {
+	c.janitor = newJanitor(c.clock, interval, c.cleanup)
+}
+
+// newJanitor 每隔 interval 调用一次 cleanup interval 为 nil 时返回 nil
+func newJanitor(clock Clock, interval *time.Duration, cleanup func()) *janitor {
+	if interval == nil {
+		return nil
+	}
+	var ticker Ticker
+	if clock, ok := clock.(TickerClock); ok {
+		ticker = clock.NewTicker(*interval)
+	} else {
+		ticker = realTicker{time.NewTicker(*interval)}
+	}
+	j := &janitor{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go j.run(ticker, cleanup)
+	return j
+}
+
+func (j *janitor) run(ticker Ticker, cleanup func()) {
+	defer close(j.done)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			cleanup()
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+// close 停止清理并等待 goroutine 退出 可以重复调用
+func (j *janitor) close() {
+	if j == nil {
+		return
+	}
+	j.once.Do(func() {
+		close(j.stop)
+	})
+	<-j.done
+}
+
+// cleanup 删除所有已经过期的元素
+func (c *baseCache) cleanup() {
+	c.mu.Lock()
+	defer c.unlock()
+	c.removeExpired()
+}
+
+// Close 停止后台清理 并等待所有异步的监听函数执行完 可以重复调用
+func (c *baseCache) Close() {
+	c.janitor.close()
+	if c.dispatcher != nil {
+		c.dispatcher.close()
+	}
+}
@@ -9,6 +9,19 @@ type Clock interface {
 	Now() time.Time
 }
 
+// Ticker 每隔一段时间向 C 发送一次当前的时间 来不及接收的时间会被丢弃
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// TickerClock 可以按照自己的时间产生定时信号的 Clock
+// 后台清理通过它计时 Clock 没有实现时使用真实的时间
+type TickerClock interface {
+	Clock
+	NewTicker(d time.Duration) Ticker
+}
+
 type RealClock struct {
 }
 
@@ -20,6 +33,18 @@ func (r RealClock) Now() time.Time {
 	return time.Now()
 }
 
+func (r RealClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
 type FakeClock interface {
 	Clock
 	Advance(d time.Duration)
@@ -30,8 +55,9 @@ func NewFakeClock() FakeClock {
 }
 
 type fakeclock struct {
-	now   time.Time
-	mutex sync.RWMutex
+	now     time.Time
+	tickers map[*fakeTicker]struct{}
+	mutex   sync.RWMutex
 }
 
 func (f *fakeclock) Now() time.Time {
@@ -40,8 +66,53 @@ func (f *fakeclock) Now() time.Time {
 	return f.now
 }
 
+// Advance 同时触发所有到期的 Ticker
 func (f *fakeclock) Advance(d time.Duration) {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 	f.now = f.now.Add(d)
+	for t := range f.tickers {
+		if t.next.After(f.now) {
+			continue
+		}
+		for !t.next.After(f.now) {
+			t.next = t.next.Add(t.d)
+		}
+		select {
+		case t.c <- f.now:
+		default:
+		}
+	}
+}
+
+func (f *fakeclock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	t := &fakeTicker{clock: f, c: make(chan time.Time, 1), d: d, next: f.now.Add(d)}
+	if f.tickers == nil {
+		f.tickers = make(map[*fakeTicker]struct{})
+	}
+	f.tickers[t] = struct{}{}
+	return t
+}
+
+// fakeTicker 只在 fakeclock.Advance 时触发
+type fakeTicker struct {
+	clock *fakeclock
+	c     chan time.Time
+	d     time.Duration
+	next  time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	delete(t.clock.tickers, t)
 }
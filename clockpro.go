@@ -0,0 +1,285 @@
+package hyliocache
+
+import (
+	"container/ring"
+	"sync/atomic"
+)
+
+/*
+clockpro 模块实现 CLOCK 和 CLOCK-Pro 淘汰策略
+元素放在一个环中 指针沿着环移动寻找可以淘汰的元素
+命中时只需要原子地设置引用位 不需要移动环中的元素
+新的元素放在指针的前一个位置 因此会最后被指针检查到
+所有元素的引用位都被设置时指针转一圈后仍会先到达新的元素 因此 Victim 会跳过最后放入的元素
+*/
+
+type clockNode struct {
+	key    interface{}
+	ref    int32 // 引用位
+	status int   // 只在 CLOCK-Pro 中使用
+}
+
+// linkBefore 把 r 放到 at 的前一个位置
+func linkBefore(at, r *ring.Ring) {
+	at.Prev().Link(r)
+}
+
+type clockPolicy struct {
+	nodes  map[interface{}]*ring.Ring
+	hand   *ring.Ring
+	newest *ring.Ring // 最后放入的元素
+}
+
+func newClockPolicy(size int) *clockPolicy {
+	return &clockPolicy{
+		nodes: make(map[interface{}]*ring.Ring, size),
+	}
+}
+
+func (p *clockPolicy) OnInsert(key interface{}) {
+	r := ring.New(1)
+	r.Value = &clockNode{key: key}
+	if p.hand == nil {
+		p.hand = r
+	} else {
+		linkBefore(p.hand, r)
+	}
+	p.nodes[key] = r
+	p.newest = r
+}
+
+// OnAccess 可以在只持有读锁时调用
+func (p *clockPolicy) OnAccess(key interface{}) {
+	if r, ok := p.nodes[key]; ok {
+		atomic.StoreInt32(&r.Value.(*clockNode).ref, 1)
+	}
+}
+
+func (p *clockPolicy) sharedAccess() {}
+
+func (p *clockPolicy) OnRemove(key interface{}) {
+	r, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	delete(p.nodes, key)
+	if p.newest == r {
+		p.newest = nil
+	}
+	if r.Next() == r {
+		p.hand = nil
+		return
+	}
+	if p.hand == r {
+		p.hand = r.Next()
+	}
+	r.Prev().Unlink(1)
+}
+
+// Victim 指针经过的元素如果设置了引用位就清除引用位 否则淘汰这个元素
+func (p *clockPolicy) Victim() (interface{}, bool) {
+	for p.hand != nil {
+		if p.hand == p.newest && p.hand.Next() != p.hand {
+			p.hand = p.hand.Next()
+			continue
+		}
+		node := p.hand.Value.(*clockNode)
+		if atomic.SwapInt32(&node.ref, 0) == 0 {
+			return node.key, true
+		}
+		p.hand = p.hand.Next()
+	}
+	return nil, false
+}
+
+const (
+	clockProHot = iota
+	clockProCold
+	clockProTest // 已经被淘汰 但还在测试期内的冷元素 只保留 key
+)
+
+// clockPro 使用三个指针
+// handCold 淘汰没有被访问过的冷元素 被访问过的冷元素升级为热元素
+// handHot 把没有被访问过的热元素降级为冷元素 并结束经过的元素的测试期
+// handTest 在测试期的元素过多时结束它们的测试期
+// 测试期内再次被写入的元素直接成为热元素 同时增加冷元素的目标容量
+type clockPro struct {
+	nodes      map[interface{}]*ring.Ring
+	size       int
+	coldTarget int
+	countHot   int
+	countCold  int
+	countTest  int
+	handHot    *ring.Ring
+	handCold   *ring.Ring
+	handTest   *ring.Ring
+	newest     *ring.Ring // 最后放入的元素
+}
+
+func newClockPro(size int) *clockPro {
+	return &clockPro{
+		nodes:      make(map[interface{}]*ring.Ring, size),
+		size:       size,
+		coldTarget: max(1, size-1),
+	}
+}
+
+func (p *clockPro) OnInsert(key interface{}) {
+	if r, ok := p.nodes[key]; ok {
+		// 测试期内的元素再次被写入 说明冷元素的容量太小
+		node := r.Value.(*clockNode)
+		p.unlink(r)
+		p.countTest--
+		p.coldTarget = min(max(1, p.size-1), p.coldTarget+1)
+		node.status = clockProHot
+		atomic.StoreInt32(&node.ref, 0)
+		p.link(r)
+		p.countHot++
+		for p.countHot > p.size-p.coldTarget {
+			p.runHandHot()
+		}
+		p.newest = r
+		return
+	}
+	r := ring.New(1)
+	r.Value = &clockNode{key: key, status: clockProCold}
+	p.link(r)
+	p.nodes[key] = r
+	p.countCold++
+	p.newest = r
+}
+
+// OnAccess 可以在只持有读锁时调用
+func (p *clockPro) OnAccess(key interface{}) {
+	if r, ok := p.nodes[key]; ok {
+		atomic.StoreInt32(&r.Value.(*clockNode).ref, 1)
+	}
+}
+
+func (p *clockPro) sharedAccess() {}
+
+// OnRemove 刚被 Victim 淘汰的元素进入测试期 只保留 key
+func (p *clockPro) OnRemove(key interface{}) {
+	r, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	switch r.Value.(*clockNode).status {
+	case clockProTest:
+		return
+	case clockProHot:
+		p.countHot--
+	case clockProCold:
+		p.countCold--
+	}
+	if p.newest == r {
+		p.newest = nil
+	}
+	p.unlink(r)
+	delete(p.nodes, key)
+}
+
+func (p *clockPro) Victim() (interface{}, bool) {
+	for {
+		if p.countCold == 0 || p.countCold == 1 && p.newestCold() {
+			// 没有可以淘汰的冷元素时先把热元素降级
+			if p.countHot > 0 {
+				p.runHandHot()
+				continue
+			}
+			if p.countCold == 0 {
+				return nil, false
+			}
+		}
+		if key, ok := p.runHandCold(); ok {
+			return key, true
+		}
+	}
+}
+
+func (p *clockPro) runHandCold() (interface{}, bool) {
+	r := p.handCold
+	p.handCold = r.Next()
+	node := r.Value.(*clockNode)
+	if node.status != clockProCold || r == p.newest && p.countCold > 1 {
+		return nil, false
+	}
+	if atomic.SwapInt32(&node.ref, 0) == 1 {
+		node.status = clockProHot
+		p.countCold--
+		p.countHot++
+		for p.countHot > p.size-p.coldTarget {
+			p.runHandHot()
+		}
+		return nil, false
+	}
+	node.status = clockProTest
+	p.countCold--
+	p.countTest++
+	for p.countTest > p.size {
+		p.runHandTest()
+	}
+	return node.key, true
+}
+
+func (p *clockPro) newestCold() bool {
+	return p.newest != nil && p.newest.Value.(*clockNode).status == clockProCold
+}
+
+func (p *clockPro) runHandHot() {
+	r := p.handHot
+	p.handHot = r.Next()
+	node := r.Value.(*clockNode)
+	switch node.status {
+	case clockProHot:
+		if atomic.SwapInt32(&node.ref, 0) == 0 {
+			node.status = clockProCold
+			p.countHot--
+			p.countCold++
+		}
+	case clockProTest:
+		p.endTest(r)
+	}
+}
+
+func (p *clockPro) runHandTest() {
+	r := p.handTest
+	p.handTest = r.Next()
+	if r.Value.(*clockNode).status == clockProTest {
+		p.endTest(r)
+	}
+}
+
+// endTest 测试期结束时仍没有被再次写入 说明冷元素的容量太大
+func (p *clockPro) endTest(r *ring.Ring) {
+	p.unlink(r)
+	delete(p.nodes, r.Value.(*clockNode).key)
+	p.countTest--
+	p.coldTarget = max(1, p.coldTarget-1)
+}
+
+// link 把 r 放到 handCold 的前一个位置 也就是最后一个被 handCold 检查的位置
+func (p *clockPro) link(r *ring.Ring) {
+	if p.handCold == nil {
+		p.handHot, p.handCold, p.handTest = r, r, r
+		return
+	}
+	linkBefore(p.handCold, r)
+}
+
+func (p *clockPro) unlink(r *ring.Ring) {
+	if r.Next() == r {
+		p.handHot, p.handCold, p.handTest = nil, nil, nil
+		return
+	}
+	if p.handHot == r {
+		p.handHot = r.Next()
+	}
+	if p.handCold == r {
+		p.handCold = r.Next()
+	}
+	if p.handTest == r {
+		p.handTest = r.Next()
+	}
+	r.Prev().Unlink(1)
+}
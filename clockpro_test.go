@@ -0,0 +1,374 @@
+package hyliocache
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestClockGet(t *testing.T) {
+	size := 1000
+	gc := buildTestCache(t, TypeClock, size)
+	testSetCache(t, gc, size)
+	testGetCache(t, gc, size)
+}
+
+func TestLoadingClockGet(t *testing.T) {
+	size := 1000
+	gc := buildTestLoadingCache(t, TypeClock, size, loader)
+	testGetCache(t, gc, size)
+}
+
+func TestClockLength(t *testing.T) {
+	gc := buildTestLoadingCache(t, TypeClock, 1000, loader)
+	gc.Get("test1")
+	gc.Get("test2")
+	length := gc.Len(true)
+	expectedLength := 2
+	if length != expectedLength {
+		t.Errorf("Expected length is %v, not %v", length, expectedLength)
+	}
+}
+
+func TestClockEvictItem(t *testing.T) {
+	cacheSize := 10
+	numbers := 11
+	gc := buildTestLoadingCache(t, TypeClock, cacheSize, loader)
+
+	for i := 0; i < numbers; i++ {
+		_, err := gc.Get(fmt.Sprintf("Key-%d", i))
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func TestClockGetIFPresent(t *testing.T) {
+	testGetIFPresent(t, TypeClock)
+}
+
+func TestClockGetCtx(t *testing.T) {
+	testGetCtx(t, TypeClock)
+}
+
+func TestClockGetMany(t *testing.T) {
+	testGetMany(t, TypeClock)
+}
+
+func TestClockCompute(t *testing.T) {
+	testCompute(t, TypeClock)
+}
+
+func TestClockRefreshAfterWrite(t *testing.T) {
+	testRefreshAfterWrite(t, TypeClock)
+}
+
+func TestClockStaleIfError(t *testing.T) {
+	testStaleIfError(t, TypeClock)
+}
+
+func TestClockNegativeTTL(t *testing.T) {
+	testNegativeTTL(t, TypeClock)
+}
+
+func TestClockWeigher(t *testing.T) {
+	testWeigher(t, TypeClock)
+}
+
+func TestClockExpireAfterAccess(t *testing.T) {
+	testExpireAfterAccess(t, TypeClock)
+}
+
+func TestClockExpiry(t *testing.T) {
+	testExpiry(t, TypeClock)
+}
+
+func TestClockCleanupInterval(t *testing.T) {
+	testCleanupInterval(t, TypeClock)
+}
+
+func TestClockEvictExpiredFirst(t *testing.T) {
+	testEvictExpiredFirst(t, TypeClock)
+}
+
+func TestClockRemovalListener(t *testing.T) {
+	testRemovalListener(t, TypeClock)
+}
+
+func TestClockAsyncListeners(t *testing.T) {
+	testAsyncListeners(t, TypeClock)
+}
+
+func TestClockPurge(t *testing.T) {
+	testPurge(t, TypeClock)
+}
+
+func TestClockStats(t *testing.T) {
+	testStats(t, TypeClock)
+}
+
+func TestClockHas(t *testing.T) {
+	gc := buildTestLoadingCacheWithExpiration(t, TypeClock, 2, 10*time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			gc.Get("test1")
+			gc.Get("test2")
+
+			if gc.Has("test0") {
+				t.Fatal("should not have test0")
+			}
+			if !gc.Has("test1") {
+				t.Fatal("should have test1")
+			}
+			if !gc.Has("test2") {
+				t.Fatal("should have test2")
+			}
+
+			time.Sleep(20 * time.Millisecond)
+
+			if gc.Has("test0") {
+				t.Fatal("should not have test0")
+			}
+			if gc.Has("test1") {
+				t.Fatal("should not have test1")
+			}
+			if gc.Has("test2") {
+				t.Fatal("should not have test2")
+			}
+		})
+	}
+}
+
+func TestClockProGet(t *testing.T) {
+	size := 1000
+	gc := buildTestCache(t, TypeClockPro, size)
+	testSetCache(t, gc, size)
+	testGetCache(t, gc, size)
+}
+
+func TestLoadingClockProGet(t *testing.T) {
+	size := 1000
+	gc := buildTestLoadingCache(t, TypeClockPro, size, loader)
+	testGetCache(t, gc, size)
+}
+
+func TestClockProLength(t *testing.T) {
+	gc := buildTestLoadingCache(t, TypeClockPro, 1000, loader)
+	gc.Get("test1")
+	gc.Get("test2")
+	length := gc.Len(true)
+	expectedLength := 2
+	if length != expectedLength {
+		t.Errorf("Expected length is %v, not %v", length, expectedLength)
+	}
+}
+
+func TestClockProEvictItem(t *testing.T) {
+	cacheSize := 10
+	numbers := 11
+	gc := buildTestLoadingCache(t, TypeClockPro, cacheSize, loader)
+
+	for i := 0; i < numbers; i++ {
+		_, err := gc.Get(fmt.Sprintf("Key-%d", i))
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func TestClockProGetIFPresent(t *testing.T) {
+	testGetIFPresent(t, TypeClockPro)
+}
+
+func TestClockProGetCtx(t *testing.T) {
+	testGetCtx(t, TypeClockPro)
+}
+
+func TestClockProGetMany(t *testing.T) {
+	testGetMany(t, TypeClockPro)
+}
+
+func TestClockProCompute(t *testing.T) {
+	testCompute(t, TypeClockPro)
+}
+
+func TestClockProRefreshAfterWrite(t *testing.T) {
+	testRefreshAfterWrite(t, TypeClockPro)
+}
+
+func TestClockProStaleIfError(t *testing.T) {
+	testStaleIfError(t, TypeClockPro)
+}
+
+func TestClockProNegativeTTL(t *testing.T) {
+	testNegativeTTL(t, TypeClockPro)
+}
+
+func TestClockProWeigher(t *testing.T) {
+	testWeigher(t, TypeClockPro)
+}
+
+func TestClockProExpireAfterAccess(t *testing.T) {
+	testExpireAfterAccess(t, TypeClockPro)
+}
+
+func TestClockProExpiry(t *testing.T) {
+	testExpiry(t, TypeClockPro)
+}
+
+func TestClockProCleanupInterval(t *testing.T) {
+	testCleanupInterval(t, TypeClockPro)
+}
+
+func TestClockProEvictExpiredFirst(t *testing.T) {
+	testEvictExpiredFirst(t, TypeClockPro)
+}
+
+func TestClockProRemovalListener(t *testing.T) {
+	testRemovalListener(t, TypeClockPro)
+}
+
+func TestClockProAsyncListeners(t *testing.T) {
+	testAsyncListeners(t, TypeClockPro)
+}
+
+func TestClockProPurge(t *testing.T) {
+	testPurge(t, TypeClockPro)
+}
+
+func TestClockProStats(t *testing.T) {
+	testStats(t, TypeClockPro)
+}
+
+func TestClockProHas(t *testing.T) {
+	gc := buildTestLoadingCacheWithExpiration(t, TypeClockPro, 2, 10*time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			gc.Get("test1")
+			gc.Get("test2")
+
+			if gc.Has("test0") {
+				t.Fatal("should not have test0")
+			}
+			if !gc.Has("test1") {
+				t.Fatal("should have test1")
+			}
+			if !gc.Has("test2") {
+				t.Fatal("should have test2")
+			}
+
+			time.Sleep(20 * time.Millisecond)
+
+			if gc.Has("test0") {
+				t.Fatal("should not have test0")
+			}
+			if gc.Has("test1") {
+				t.Fatal("should not have test1")
+			}
+			if gc.Has("test2") {
+				t.Fatal("should not have test2")
+			}
+		})
+	}
+}
+
+func TestClockSecondChance(t *testing.T) {
+	size := 10
+	gc := buildTestCache(t, TypeClock, size)
+	setItemsByRange(t, gc, 0, size)
+	// 设置了引用位的元素会被跳过一次
+	gc.Get(0)
+	gc.Get(1)
+	setItemsByRange(t, gc, 100, 102)
+	for i := 0; i < 4; i++ {
+		if has := gc.Has(i); has != (i < 2) {
+			t.Fatalf("Has(%v) should be %v", i, i < 2)
+		}
+	}
+}
+
+func TestClockHotWorkingSet(t *testing.T) {
+	for _, tp := range []string{TypeClock, TypeClockPro} {
+		t.Run(tp, func(t *testing.T) {
+			var added []interface{}
+			gc := New(3).
+				EvictType(tp).
+				AddedFunc(func(key, value interface{}) {
+					added = append(added, key)
+				}).
+				Build()
+			setItemsByRange(t, gc, 0, 3)
+			// 所有元素的引用位都被设置时 新的元素也不能被立即淘汰
+			for i := 0; i < 3; i++ {
+				gc.Get(i)
+			}
+			if err := gc.SetWithExpire("new", "new", time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			if v, err := gc.Get("new"); err != nil || v != "new" {
+				t.Fatalf("new key should be in the cache, got %v %v", v, err)
+			}
+			if l := gc.Len(false); l != 3 {
+				t.Fatalf("%v != 3", l)
+			}
+			if len(added) != 4 {
+				t.Fatalf("AddedFunc should be called 4 times, not %v", len(added))
+			}
+			if n := gc.(*policyCache).timers.Len(); n != 1 {
+				t.Fatalf("expiry queue should only hold the new key, %d items", n)
+			}
+		})
+	}
+}
+
+func TestClockProHotKeys(t *testing.T) {
+	size := 100
+	gc := buildTestCache(t, TypeClockPro, size)
+	for i := 0; i < 3; i++ {
+		setItemsByRange(t, gc, 0, 50)
+		for j := 0; j < 50; j++ {
+			gc.Get(j)
+		}
+	}
+
+	for i := 1000; i < 2000; i++ {
+		gc.Set(i, i)
+		// 热元素持续被访问
+		gc.Get(i % 50)
+	}
+	if l := gc.Len(false); l != size {
+		t.Fatalf("%v != %v", l, size)
+	}
+	for i := 0; i < 50; i++ {
+		if !gc.Has(i) {
+			t.Fatalf("hot key %v should survive the scan", i)
+		}
+	}
+}
+
+func TestClockProRandomOps(t *testing.T) {
+	size := 10
+	gc := New(size).EvictType(TypeClockPro).Build()
+	p := gc.(*policyCache).policy.(*clockPro)
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		key := rnd.Intn(3 * size)
+		switch rnd.Intn(4) {
+		case 0, 1:
+			gc.Set(key, key)
+		case 2:
+			gc.Get(key)
+		case 3:
+			gc.Remove(key)
+		}
+		if l := gc.Len(false); l > size || l != p.countHot+p.countCold {
+			t.Fatalf("length %v, hot %v, cold %v", l, p.countHot, p.countCold)
+		}
+		if p.countTest > size {
+			t.Fatalf("%v > %v", p.countTest, size)
+		}
+	}
+}
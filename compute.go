@@ -0,0 +1,125 @@
+package hyliocache
+
+import "errors"
+
+/*
+compute 模块提供在一次加锁中完成的读-改-写操作
+所有操作都在 baseCache.mu 中完成 因此不会丢失并发的更新
+修改已经存在的元素时保留它单独设置的过期时间
+*/
+
+var ValueNotIntegerError = errors.New("value is not an integer")
+
+// ComputeFunc 接收当前的值以及它是否存在
+// 返回新的值以及是否保留 不保留时对应的元素会被删除
+type ComputeFunc func(old interface{}, exists bool) (interface{}, bool)
+
+// itemStore 是各个淘汰策略在持有 mu 时对元素的基本操作
+type itemStore interface {
+	lookup(key interface{}) (*cacheItem, bool)
+	peek(key interface{}) (*cacheItem, bool)
+	// set 写入元素 写入的元素被立即淘汰时返回 nil
+	set(key, value interface{}) (*cacheItem, error)
+	remove(key interface{}, cause RemovalCause) bool
+	// init 清空所有的元素并重置内部结构
+	init()
+	// each 依次访问所有的元素 包括已经过期的元素
+	each(fn func(item *cacheItem))
+	length() int
+}
+
+// sharedStore 由命中时不需要修改内部结构的淘汰策略实现
+// lookupShared 在持有读锁时调用 返回 false 时会再持有写锁调用 lookup
+type sharedStore interface {
+	lookupShared(key interface{}) (*cacheItem, bool)
+}
+
+// update 与 store.set 相同 但 key 已经存在时保留它单独设置的过期时间 调用时需持有 mu
+func (c *baseCache) update(key, value interface{}) (*cacheItem, error) {
+	c.keepTTL = true
+	defer func() {
+		c.keepTTL = false
+	}()
+	return c.store.set(key, value)
+}
+
+// GetOrSet 返回 key 对应的值 如果不存在就设置为 value
+// loaded 表示返回的是否是已经存在的值
+func (c *baseCache) GetOrSet(key, value interface{}) (actual interface{}, loaded bool, err error) {
+	c.mu.Lock()
+	defer c.unlock()
+	if item, ok := c.lookup(key); ok {
+		c.stats.IncrHitCount()
+		return item.value, true, nil
+	}
+	c.stats.IncrMissCount()
+	if _, err := c.store.set(key, value); err != nil {
+		return nil, false, err
+	}
+	return value, false, nil
+}
+
+// Compute 用 fn 的结果替换 key 对应的值
+// fn 在持有锁时调用 因此不能在 fn 中再访问缓存
+func (c *baseCache) Compute(key interface{}, fn ComputeFunc) (interface{}, error) {
+	c.mu.Lock()
+	defer c.unlock()
+	var old interface{}
+	item, exists := c.lookup(key)
+	if exists {
+		old = item.value
+	}
+	v, keep := fn(old, exists)
+	if !keep {
+		if exists {
+			c.store.remove(key, RemovalExplicit)
+		}
+		return nil, nil
+	}
+	if _, err := c.update(key, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// CompareAndSwap 只有当 key 对应的值等于 old 时才替换为 new
+// 值之间使用 == 比较 类型不同或者不可比较的值 (例如 []byte) 视为不相等
+func (c *baseCache) CompareAndSwap(key, old, new interface{}) bool {
+	c.mu.Lock()
+	defer c.unlock()
+	item, ok := c.lookup(key)
+	if !ok || !equal(item.value, old) {
+		return false
+	}
+	_, err := c.update(key, new)
+	return err == nil
+}
+
+// Incr 把 key 对应的整数加上 delta 并返回新的值
+// key 不存在时视为 int64(0) 新的值保持原有的整数类型
+func (c *baseCache) Incr(key interface{}, delta int64) (int64, error) {
+	return c.IncrFrom(key, delta, int64(0))
+}
+
+// IncrFrom 与 Incr 相同 但 key 不存在时从 initial 开始计算 新的值与 initial 类型相同
+// 出错时不会修改缓存
+func (c *baseCache) IncrFrom(key interface{}, delta int64, initial interface{}) (int64, error) {
+	c.mu.Lock()
+	defer c.unlock()
+	old := initial
+	if item, ok := c.lookup(key); ok {
+		old = item.value
+	}
+	v, n, err := addInt(old, delta)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := c.update(key, v); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (c *baseCache) Decr(key interface{}, delta int64) (int64, error) {
+	return c.Incr(key, -delta)
+}
@@ -0,0 +1,39 @@
+package hyliocache
+
+import "time"
+
+/*
+cost 模块记录重新加载每个元素的代价 供 GDSF 这样考虑代价的淘汰策略使用
+通过加载器加载的元素的代价是加载耗时的毫秒数 其他元素的代价默认为 1
+*/
+
+// costStore 由需要知道元素代价的淘汰策略实现
+type costStore interface {
+	costChanged(item *cacheItem)
+}
+
+// SetWithCost 与 Set 相同 同时设置元素的代价
+// 只有 GDSF 这样考虑代价的淘汰策略会使用代价
+func (c *baseCache) SetWithCost(key, value interface{}, cost float64) error {
+	c.mu.Lock()
+	defer c.unlock()
+	item, err := c.store.set(key, value)
+	if err != nil || item == nil {
+		return err
+	}
+	c.setCost(item, cost)
+	return nil
+}
+
+// setCost 调用时需持有 mu
+func (c *baseCache) setCost(item *cacheItem, cost float64) {
+	item.cost = cost
+	if s, ok := c.store.(costStore); ok {
+		s.costChanged(item)
+	}
+}
+
+// loadCost 把加载的耗时换算为代价
+func loadCost(elapsed time.Duration) float64 {
+	return float64(elapsed) / float64(time.Millisecond)
+}
@@ -0,0 +1,254 @@
+package hyliocache
+
+import (
+	"bytes"
+	"hash/maphash"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+dispatch 模块在锁外异步调用 AddedFunc EvictedFunc 和 RemovalListener
+持有 mu 时产生的事件按照产生的顺序放入 key 对应的 worker 的 pending 中
+释放 mu 之后再把 pending 中的事件依次交给 worker 因此同一个 key 的事件按照产生的顺序执行
+*/
+
+// FullPolicy 决定 dispatcher 的队列满了之后如何处理新的事件
+type FullPolicy int
+
+const (
+	// FullBlock 等待队列有空位
+	// 监听函数写回缓存时不会等待 worker 执行完当前的监听函数之后再放入新的事件
+	FullBlock FullPolicy = iota
+	// FullDrop 丢弃新的事件
+	FullDrop
+	// FullInline 在产生事件的 goroutine 中直接执行 此时不再保证同一个 key 的顺序
+	FullInline
+)
+
+const (
+	eventAdded = iota
+	eventRemoved
+)
+
+type event struct {
+	kind       int
+	key, value interface{}
+	cause      RemovalCause
+	cache      *baseCache // 产生事件的缓存 分片共享 dispatcher 时由它调用监听函数
+}
+
+// worker 按照顺序执行同一组 key 的事件
+type worker struct {
+	queue   chan event
+	mu      sync.Mutex // 保护 pending
+	pending []event    // 已经产生但还没有放入 queue 的事件
+	send    sync.Mutex // 保证 pending 中的事件按照顺序放入 queue
+	goid    atomic.Uint64
+}
+
+type dispatcher struct {
+	workers []*worker
+	full    FullPolicy
+	seed    maphash.Seed
+	mu      sync.RWMutex // 放入 queue 时持有读锁 关闭 queue 时持有写锁
+	closed  atomic.Bool
+	wg      sync.WaitGroup
+}
+
+// newDispatcher 创建 GOMAXPROCS 个 worker queueSize 平均分配给每个 worker
+func newDispatcher(queueSize int, full FullPolicy) *dispatcher {
+	d := &dispatcher{
+		workers: make([]*worker, runtime.GOMAXPROCS(0)),
+		full:    full,
+		seed:    maphash.MakeSeed(),
+	}
+	size := divCeil(queueSize, len(d.workers))
+	d.wg.Add(len(d.workers))
+	for i := range d.workers {
+		d.workers[i] = &worker{queue: make(chan event, size)}
+		go d.run(d.workers[i])
+	}
+	return d
+}
+
+func (d *dispatcher) run(w *worker) {
+	defer d.wg.Done()
+	w.goid.Store(goid())
+	for e := range w.queue {
+		e.cache.fire(e)
+		// 监听函数写回缓存时 队列满了之后事件会留在 pending 中
+		if w.hasPending() {
+			d.flush(w)
+		}
+	}
+}
+
+func (w *worker) hasPending() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.pending) > 0
+}
+
+// add 把事件放入 key 对应的 worker 的 pending 调用时需持有产生事件的缓存的 mu
+// 已经关闭时返回 nil
+func (d *dispatcher) add(e event) *worker {
+	w := d.workers[maphash.Comparable(d.seed, e.key)%uint64(len(d.workers))]
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if d.closed.Load() {
+		return nil
+	}
+	w.pending = append(w.pending, e)
+	return w
+}
+
+// flush 把 w.pending 中的事件依次放入 queue 调用时不能持有缓存的 mu
+// 其他 goroutine 正在放入时直接返回 它释放 send 之后会再检查 pending
+// 正在关闭时也直接返回 pending 中的事件由 close 执行
+func (d *dispatcher) flush(w *worker) {
+	var inline []event
+	for d.mu.TryRLock() {
+		if !w.send.TryLock() {
+			d.mu.RUnlock()
+			break
+		}
+		blocked := d.push(w, &inline)
+		w.send.Unlock()
+		d.mu.RUnlock()
+		if blocked || !w.hasPending() {
+			break
+		}
+	}
+	// 释放锁之后再执行 监听函数中可以访问缓存
+	for _, e := range inline {
+		e.cache.fire(e)
+	}
+}
+
+// push 把 pending 中的事件依次放入 queue 调用时需持有 d.mu 的读锁和 w.send
+// worker 自己在监听函数中写回缓存时不能等待自己的队列 此时返回 true 剩下的事件留在 pending 中
+func (d *dispatcher) push(w *worker, inline *[]event) bool {
+	for !d.closed.Load() {
+		w.mu.Lock()
+		if len(w.pending) == 0 {
+			w.mu.Unlock()
+			return false
+		}
+		e := w.pending[0]
+		w.mu.Unlock()
+		select {
+		case w.queue <- e:
+		default:
+			switch d.full {
+			case FullDrop:
+			case FullInline:
+				*inline = append(*inline, e)
+			default:
+				if goid() == w.goid.Load() {
+					return true
+				}
+				w.queue <- e
+			}
+		}
+		w.mu.Lock()
+		w.pending[0] = event{}
+		w.pending = w.pending[1:]
+		w.mu.Unlock()
+	}
+	return false
+}
+
+// close 等待所有已经提交的事件执行完 可以重复调用
+func (d *dispatcher) close() {
+	d.mu.Lock()
+	if d.closed.Load() {
+		d.mu.Unlock()
+		return
+	}
+	d.closed.Store(true)
+	var rest []event
+	for _, w := range d.workers {
+		close(w.queue)
+		w.mu.Lock()
+		rest = append(rest, w.pending...)
+		w.pending = nil
+		w.mu.Unlock()
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+	// 还没有放入 queue 的事件排在 queue 中的事件之后
+	for _, e := range rest {
+		e.cache.fire(e)
+	}
+}
+
+// goid 返回当前 goroutine 的 id 只在 worker 启动以及队列满了的时候调用
+func goid() uint64 {
+	var buf [64]byte
+	b := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+// unlock 释放 mu 然后把持有 mu 时产生的事件交给 worker
+func (c *baseCache) unlock() {
+	workers, events := c.workers, c.events
+	c.workers, c.events = nil, nil
+	c.mu.Unlock()
+	for _, w := range workers {
+		c.dispatcher.flush(w)
+	}
+	for _, e := range events {
+		c.fire(e)
+	}
+}
+
+// notify 调用监听函数 开启了异步监听时只记录事件 调用时需持有 mu
+func (c *baseCache) notify(e event) {
+	if c.dispatcher == nil {
+		c.fire(e)
+		return
+	}
+	e.cache = c
+	w := c.dispatcher.add(e)
+	if w == nil {
+		// dispatcher 已经关闭 释放 mu 之后直接执行
+		c.events = append(c.events, e)
+		return
+	}
+	for _, x := range c.workers {
+		if x == w {
+			return
+		}
+	}
+	c.workers = append(c.workers, w)
+}
+
+func (c *baseCache) fire(e event) {
+	switch e.kind {
+	case eventAdded:
+		if c.addedFunc != nil {
+			c.addedFunc(e.key, e.value)
+		}
+	case eventRemoved:
+		if c.evictedFunc != nil && e.cause != RemovalReplaced && e.cause != RemovalCleared {
+			c.evictedFunc(e.key, e.value)
+		}
+		if c.removalListener != nil {
+			c.removalListener(e.key, e.value, e.cause)
+		}
+	}
+}
+
+// added 在元素被写入之后调用 调用时需持有 mu
+func (c *baseCache) added(key, value interface{}) {
+	if c.addedFunc != nil {
+		c.notify(event{kind: eventAdded, key: key, value: value})
+	}
+}
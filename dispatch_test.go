@@ -0,0 +1,144 @@
+package hyliocache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAsyncListenersFullPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		full FullPolicy
+		name string
+	}{
+		{FullDrop, "drop"},
+		{FullInline, "inline"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var fired int64
+			release := make(chan struct{})
+			cache := New(8).
+				LRU().
+				AsyncListeners(1, tc.full).
+				AddedFunc(func(key, value interface{}) {
+					if value == 0 {
+						<-release
+					}
+					atomic.AddInt64(&fired, 1)
+				}).
+				Build()
+			// 第一个事件阻塞 worker 第二个事件占满队列
+			cache.Set("a", 0)
+			cache.Set("a", 1)
+			for i := 2; i < 10; i++ {
+				cache.Set("a", 2)
+			}
+			if tc.full == FullInline {
+				if n := atomic.LoadInt64(&fired); n < 8 {
+					t.Fatalf("events should run inline when the queue is full, fired %d", n)
+				}
+			}
+			close(release)
+			cache.Close()
+			n := atomic.LoadInt64(&fired)
+			switch {
+			case tc.full == FullDrop && n >= 10:
+				t.Fatalf("events should be dropped when the queue is full, fired %d", n)
+			case tc.full == FullInline && n != 10:
+				t.Fatalf("expected 10 events, fired %d", n)
+			}
+		})
+	}
+}
+
+func TestAsyncListenersAfterClose(t *testing.T) {
+	var fired int64
+	cache := New(8).
+		LRU().
+		AsyncListeners(8, FullBlock).
+		AddedFunc(func(key, value interface{}) {
+			atomic.AddInt64(&fired, 1)
+		}).
+		Build()
+	cache.Close()
+	cache.Close()
+	cache.Set("a", 1)
+	if n := atomic.LoadInt64(&fired); n != 1 {
+		t.Fatalf("events should run synchronously after Close, fired %d", n)
+	}
+}
+
+func TestAsyncListenersOrder(t *testing.T) {
+	var values []int64
+	cache := New(8).
+		LRU().
+		AsyncListeners(1024, FullBlock).
+		AddedFunc(func(key, value interface{}) {
+			// 同一个 key 的事件只由一个 worker 执行
+			values = append(values, value.(int64))
+		}).
+		Build()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				cache.Incr("k", 1)
+			}
+		}()
+	}
+	wg.Wait()
+	cache.Close()
+	if len(values) != 16000 {
+		t.Fatalf("expected 16000 events, fired %d", len(values))
+	}
+	for i, v := range values {
+		if v != int64(i+1) {
+			t.Fatalf("events of the same key should run in order, got %d at %d", v, i)
+		}
+	}
+}
+
+func TestAsyncListenersReentrant(t *testing.T) {
+	var cache Cache
+	cache = New(100).
+		LRU().
+		AsyncListeners(1, FullBlock).
+		AddedFunc(func(key, value interface{}) {
+			// 监听函数写回缓存 worker 自己的队列已满时也不能阻塞
+			if v := value.(int); v < 50 {
+				cache.Set(key, v+1)
+			}
+		}).
+		Build()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 20; i++ {
+					cache.Set(g*100+i, 0)
+				}
+			}(g)
+		}
+		wg.Wait()
+		cache.Close()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("listener writing back into the cache should not hang the cache")
+	}
+	for g := 0; g < 4; g++ {
+		for i := 0; i < 20; i++ {
+			if v, err := cache.Get(g*100 + i); err != nil || v != 50 {
+				t.Fatalf("key %v should reach 50, got %v %v", g*100+i, v, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package hyliocache
+
+import (
+	"math"
+	"time"
+)
+
+/*
+expiry 模块让每个元素的过期时间可以由 key 和 value 决定
+设置了 Expiry 之后 Expiration 和 ExpireAfterAccess 不再生效
+SetWithExpire 以及加载器返回的过期时间仍然会覆盖 Expiry 计算的结果
+*/
+
+// NeverExpire 表示元素不会过期
+const NeverExpire time.Duration = math.MaxInt64
+
+// Expiry 返回元素从 now 开始还能存活多久 返回 NeverExpire 表示不会过期
+// remaining 是元素当前剩余的存活时间 返回 remaining 表示不改变过期时间
+// 所有方法都在持有锁时调用 因此不能在其中访问缓存
+type Expiry interface {
+	// ExpireAfterCreate 新的元素被写入
+	ExpireAfterCreate(key, value interface{}, now time.Time) time.Duration
+	// ExpireAfterUpdate 已经存在的元素被重新写入
+	ExpireAfterUpdate(key, value interface{}, now time.Time, remaining time.Duration) time.Duration
+	// ExpireAfterRead 元素被命中
+	ExpireAfterRead(key, value interface{}, now time.Time, remaining time.Duration) time.Duration
+}
+
+// expireAfterWrite 使用 Expiry 计算刚写入的元素的过期时间 调用时需持有 mu
+func (c *baseCache) expireAfterWrite(item *cacheItem, created bool, now time.Time) {
+	var d time.Duration
+	if created {
+		d = c.expiry.ExpireAfterCreate(item.key, item.value, now)
+	} else {
+		d = c.expiry.ExpireAfterUpdate(item.key, item.value, now, remaining(item, now))
+	}
+	setTTL(item, d, now)
+}
+
+// expireAfterRead 使用 Expiry 计算被命中的元素的过期时间 调用时需持有 mu
+func (c *baseCache) expireAfterRead(item *cacheItem, now time.Time) {
+	setTTL(item, c.expiry.ExpireAfterRead(item.key, item.value, now, remaining(item, now)), now)
+}
+
+// remaining 返回元素剩余的存活时间
+func remaining(item *cacheItem, now time.Time) time.Duration {
+	if item.expiration == nil {
+		return NeverExpire
+	}
+	return item.expiration.Sub(now)
+}
+
+func setTTL(item *cacheItem, d time.Duration, now time.Time) {
+	item.idle = nil
+	if d == NeverExpire {
+		item.deadline = nil
+		item.expiration = nil
+		item.timers.remove(item)
+		return
+	}
+	item.expireAt(now.Add(d), now)
+}
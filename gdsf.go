@@ -0,0 +1,125 @@
+package hyliocache
+
+import "container/heap"
+
+/*
+gdsf 模块实现 GreedyDual-Size-Frequency 淘汰策略
+每个元素的优先级为 L + 访问次数 * 代价 / 重量 淘汰优先级最低的元素
+L 是最近一次被淘汰的元素的优先级 新的元素因此不会一直输给很久以前的热点
+代价通过 SetWithCost 设置 或者由加载器的耗时决定 重量由 Weigher 决定
+*/
+
+type gdsfEntry struct {
+	key      interface{}
+	freq     uint64
+	cost     float64
+	weight   int64
+	priority float64
+	index    int
+}
+
+type gdsf struct {
+	entries   map[interface{}]*gdsfEntry
+	heap      gdsfHeap
+	inflation float64     // L
+	last      interface{} // 最近一次写入或者访问的 key 不会被淘汰
+}
+
+func newGDSF(size int) *gdsf {
+	return &gdsf{
+		entries: make(map[interface{}]*gdsfEntry, size),
+	}
+}
+
+func (p *gdsf) OnInsert(key interface{}) {
+	e := &gdsfEntry{key: key, freq: 1, cost: 1, weight: 1}
+	e.priority = p.priority(e)
+	p.entries[key] = e
+	heap.Push(&p.heap, e)
+	p.last = key
+}
+
+func (p *gdsf) OnAccess(key interface{}) {
+	e, ok := p.entries[key]
+	if !ok {
+		return
+	}
+	e.freq++
+	p.update(e)
+	p.last = key
+}
+
+func (p *gdsf) OnCost(key interface{}, cost float64, weight int64) {
+	e, ok := p.entries[key]
+	if !ok {
+		return
+	}
+	if weight < 1 {
+		weight = 1
+	}
+	e.cost, e.weight = cost, weight
+	p.update(e)
+}
+
+func (p *gdsf) OnRemove(key interface{}) {
+	e, ok := p.entries[key]
+	if !ok {
+		return
+	}
+	heap.Remove(&p.heap, e.index)
+	delete(p.entries, key)
+}
+
+// Victim 返回优先级最低的元素 正在写入的元素只有在只剩它自己时才会被淘汰
+func (p *gdsf) Victim() (interface{}, bool) {
+	if len(p.heap) == 0 {
+		return nil, false
+	}
+	victim := p.heap[0]
+	if victim.key == p.last && len(p.heap) > 1 {
+		// 堆顶之后优先级最低的元素一定是它的一个子结点
+		victim = p.heap[1]
+		if len(p.heap) > 2 && p.heap[2].priority < victim.priority {
+			victim = p.heap[2]
+		}
+	}
+	p.inflation = victim.priority
+	return victim.key, true
+}
+
+func (p *gdsf) priority(e *gdsfEntry) float64 {
+	return p.inflation + float64(e.freq)*e.cost/float64(e.weight)
+}
+
+func (p *gdsf) update(e *gdsfEntry) {
+	e.priority = p.priority(e)
+	heap.Fix(&p.heap, e.index)
+}
+
+// gdsfHeap 是按照优先级排列的最小堆
+type gdsfHeap []*gdsfEntry
+
+func (h gdsfHeap) Len() int { return len(h) }
+
+func (h gdsfHeap) Less(i, j int) bool { return h[i].priority < h[j].priority }
+
+func (h gdsfHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *gdsfHeap) Push(x interface{}) {
+	e := x.(*gdsfEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *gdsfHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
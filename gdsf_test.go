@@ -0,0 +1,195 @@
+package hyliocache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGDSFGet(t *testing.T) {
+	size := 1000
+	gc := buildTestCache(t, TypeGDSF, size)
+	testSetCache(t, gc, size)
+	testGetCache(t, gc, size)
+}
+
+func TestLoadingGDSFGet(t *testing.T) {
+	size := 1000
+	gc := buildTestLoadingCache(t, TypeGDSF, size, loader)
+	testGetCache(t, gc, size)
+}
+
+func TestGDSFLength(t *testing.T) {
+	gc := buildTestLoadingCache(t, TypeGDSF, 1000, loader)
+	gc.Get("test1")
+	gc.Get("test2")
+	length := gc.Len(true)
+	expectedLength := 2
+	if length != expectedLength {
+		t.Errorf("Expected length is %v, not %v", length, expectedLength)
+	}
+}
+
+func TestGDSFEvictItem(t *testing.T) {
+	cacheSize := 10
+	numbers := 11
+	gc := buildTestLoadingCache(t, TypeGDSF, cacheSize, loader)
+
+	for i := 0; i < numbers; i++ {
+		_, err := gc.Get(fmt.Sprintf("Key-%d", i))
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func TestGDSFGetIFPresent(t *testing.T) {
+	testGetIFPresent(t, TypeGDSF)
+}
+
+func TestGDSFGetCtx(t *testing.T) {
+	testGetCtx(t, TypeGDSF)
+}
+
+func TestGDSFGetMany(t *testing.T) {
+	testGetMany(t, TypeGDSF)
+}
+
+func TestGDSFCompute(t *testing.T) {
+	testCompute(t, TypeGDSF)
+}
+
+func TestGDSFRefreshAfterWrite(t *testing.T) {
+	testRefreshAfterWrite(t, TypeGDSF)
+}
+
+func TestGDSFStaleIfError(t *testing.T) {
+	testStaleIfError(t, TypeGDSF)
+}
+
+func TestGDSFNegativeTTL(t *testing.T) {
+	testNegativeTTL(t, TypeGDSF)
+}
+
+func TestGDSFWeigher(t *testing.T) {
+	testWeigher(t, TypeGDSF)
+}
+
+func TestGDSFExpireAfterAccess(t *testing.T) {
+	testExpireAfterAccess(t, TypeGDSF)
+}
+
+func TestGDSFExpiry(t *testing.T) {
+	testExpiry(t, TypeGDSF)
+}
+
+func TestGDSFCleanupInterval(t *testing.T) {
+	testCleanupInterval(t, TypeGDSF)
+}
+
+func TestGDSFEvictExpiredFirst(t *testing.T) {
+	testEvictExpiredFirst(t, TypeGDSF)
+}
+
+func TestGDSFRemovalListener(t *testing.T) {
+	testRemovalListener(t, TypeGDSF)
+}
+
+func TestGDSFAsyncListeners(t *testing.T) {
+	testAsyncListeners(t, TypeGDSF)
+}
+
+func TestGDSFPurge(t *testing.T) {
+	testPurge(t, TypeGDSF)
+}
+
+func TestGDSFStats(t *testing.T) {
+	testStats(t, TypeGDSF)
+}
+
+func TestGDSFHas(t *testing.T) {
+	gc := buildTestLoadingCacheWithExpiration(t, TypeGDSF, 2, 10*time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			gc.Get("test1")
+			gc.Get("test2")
+
+			if gc.Has("test0") {
+				t.Fatal("should not have test0")
+			}
+			if !gc.Has("test1") {
+				t.Fatal("should have test1")
+			}
+			if !gc.Has("test2") {
+				t.Fatal("should have test2")
+			}
+
+			time.Sleep(20 * time.Millisecond)
+
+			if gc.Has("test0") {
+				t.Fatal("should not have test0")
+			}
+			if gc.Has("test1") {
+				t.Fatal("should not have test1")
+			}
+			if gc.Has("test2") {
+				t.Fatal("should not have test2")
+			}
+		})
+	}
+}
+
+func TestGDSFCost(t *testing.T) {
+	gc := buildTestCache(t, TypeGDSF, 3)
+	gc.SetWithCost("expensive", 1, 100)
+	gc.SetWithCost("cheap", 2, 1)
+	gc.SetWithCost("medium", 3, 10)
+	gc.Set("new", 4)
+	if gc.Has("cheap") {
+		t.Fatal("cheap should be evicted")
+	}
+	gc.Set("newer", 5)
+	if gc.Has("new") {
+		t.Fatal("new should be evicted")
+	}
+	if !gc.Has("expensive") || !gc.Has("medium") || !gc.Has("newer") {
+		t.Fatal("expensive, medium and newer should not be evicted")
+	}
+}
+
+func TestGDSFLoadCost(t *testing.T) {
+	gc := New(2).
+		GDSF().
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			if key == "slow" {
+				time.Sleep(20 * time.Millisecond)
+			}
+			return key, nil
+		}).
+		Build()
+	for _, key := range []string{"slow", "fast1", "fast2", "fast3"} {
+		if _, err := gc.Get(key); err != nil {
+			t.Fatalf("err should not be %v", err)
+		}
+	}
+	if !gc.Has("slow") || !gc.Has("fast3") {
+		t.Fatal("slow key should be kept")
+	}
+}
+
+func TestGDSFWeight(t *testing.T) {
+	gc := New(3).
+		GDSF().
+		Weigher(func(key, value interface{}) int64 {
+			return int64(len(value.(string)))
+		}).
+		Build()
+	gc.Set("big", "xxxxxxxxxx")
+	gc.Set("small1", "x")
+	gc.Set("small2", "x")
+	gc.Set("small3", "x")
+	if gc.Has("big") {
+		t.Fatal("big should be evicted")
+	}
+}
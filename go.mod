@@ -1 +1,3 @@
 module github.com/hylio/Cache
+
+go 1.24
@@ -1,7 +1,11 @@
 package hyliocache
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -62,6 +66,863 @@ func testGetIFPresent(t *testing.T, evT string) {
 	}
 }
 
+func testGetCtx(t *testing.T, evT string) {
+	cache :=
+		New(8).
+			EvictType(evT).
+			LoaderCtxFunc(
+				func(ctx context.Context, key interface{}) (interface{}, error) {
+					if key == "slow" {
+						<-ctx.Done()
+						return nil, ctx.Err()
+					}
+					return "value", nil
+				}).
+			Build()
+
+	v, err := cache.GetCtx(context.Background(), "key")
+	if err != nil {
+		t.Errorf("err should not be %v", err)
+	}
+	if v != "value" {
+		t.Errorf("v should not be %v", v)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = cache.GetCtx(ctx, "slow")
+	if err != context.DeadlineExceeded {
+		t.Errorf("err should be %v, not %v", context.DeadlineExceeded, err)
+	}
+	if cache.Has("slow") {
+		t.Error("should not have slow")
+	}
+}
+
+func testGetMany(t *testing.T, evT string) {
+	var calls, loaded int
+	cache :=
+		New(16).
+			EvictType(evT).
+			BatchLoaderFunc(
+				func(keys []interface{}) (map[interface{}]interface{}, error) {
+					calls++
+					loaded += len(keys)
+					m := make(map[interface{}]interface{}, len(keys))
+					for _, k := range keys {
+						if k.(int) < 8 {
+							m[k] = k
+						}
+					}
+					return m, nil
+				}).
+			Build()
+
+	if err := cache.SetMany(map[interface{}]interface{}{0: 0, 1: 1}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := cache.GetMany([]interface{}{0, 1, 2, 3, 3, 9})
+	if err != nil {
+		t.Fatalf("err should not be %v", err)
+	}
+	if len(m) != 4 {
+		t.Fatalf("%v != 4", len(m))
+	}
+	for k, v := range m {
+		if k != v {
+			t.Errorf("%v != %v", k, v)
+		}
+	}
+	if _, ok := m[9]; ok {
+		t.Error("m should not contain 9")
+	}
+	if calls != 1 || loaded != 3 {
+		t.Errorf("batch loader should be called once with 3 keys, not %v calls with %v keys", calls, loaded)
+	}
+	if !cache.Has(2) || !cache.Has(3) {
+		t.Error("loaded keys should be set")
+	}
+}
+
+func testCompute(t *testing.T, evT string) {
+	var added, evicted []interface{}
+	cache :=
+		New(8).
+			EvictType(evT).
+			AddedFunc(func(key, value interface{}) {
+				added = append(added, key)
+			}).
+			EvictedFunc(func(key, value interface{}) {
+				evicted = append(evicted, key)
+			}).
+			Build()
+
+	v, loaded, err := cache.GetOrSet("key", 1)
+	if err != nil || loaded || v != 1 {
+		t.Fatalf("GetOrSet = %v, %v, %v", v, loaded, err)
+	}
+	v, loaded, err = cache.GetOrSet("key", 2)
+	if err != nil || !loaded || v != 1 {
+		t.Fatalf("GetOrSet = %v, %v, %v", v, loaded, err)
+	}
+	if cache.CompareAndSwap("key", 2, 3) {
+		t.Fatal("CompareAndSwap should fail")
+	}
+	if !cache.CompareAndSwap("key", 1, 3) {
+		t.Fatal("CompareAndSwap should succeed")
+	}
+	v, err = cache.Compute("key", func(old interface{}, exists bool) (interface{}, bool) {
+		if !exists {
+			t.Error("key should exist")
+		}
+		return old.(int) * 2, true
+	})
+	if err != nil || v != 6 {
+		t.Fatalf("Compute = %v, %v", v, err)
+	}
+	if len(added) != 3 {
+		t.Fatalf("added should be called 3 times, not %v", len(added))
+	}
+	cache.Compute("key", func(old interface{}, exists bool) (interface{}, bool) {
+		return nil, false
+	})
+	if cache.Has("key") {
+		t.Fatal("should not have key")
+	}
+	if len(evicted) != 1 {
+		t.Fatalf("evicted should be called once, not %v", len(evicted))
+	}
+	// ARC 中被删除的 key 会留在幽灵链表中 再次写入时也要调用 added
+	cache.Compute("key", func(old interface{}, exists bool) (interface{}, bool) {
+		return 1, true
+	})
+	cache.Remove("key")
+	cache.Set("key", 2)
+	if len(added) != 5 {
+		t.Fatalf("added should be called 5 times, not %v", len(added))
+	}
+
+	cache.Set("bytes", []byte("value"))
+	if cache.CompareAndSwap("bytes", []byte("value"), []byte("new")) {
+		t.Fatal("CompareAndSwap should fail on values that are not comparable")
+	}
+	if cache.CompareAndSwap("key", int64(2), 3) {
+		t.Fatal("CompareAndSwap should fail on values of different types")
+	}
+
+	cache.Set("str", "value")
+	if _, err := cache.Incr("str", 1); err != ValueNotIntegerError {
+		t.Fatalf("err should be ValueNotIntegerError, not %v", err)
+	}
+	cache.Set("int", 10)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Incr("int", 2)
+			cache.Decr("int", 1)
+		}()
+	}
+	wg.Wait()
+	if v, _ := cache.Get("int"); v != 110 {
+		t.Fatalf("%v != 110", v)
+	}
+	if n, err := cache.Incr("counter", 5); err != nil || n != 5 {
+		t.Fatalf("Incr = %v, %v", n, err)
+	}
+
+	// 读-改-写操作保留单独设置的过期时间
+	clock := NewFakeClock()
+	cache = New(8).EvictType(evT).Clock(clock).Build()
+	cache.SetWithExpire("counter", int64(0), time.Minute)
+	cache.SetWithExpire("computed", 0, time.Minute)
+	cache.SetWithExpireAfterAccessAndLifetime("swapped", 0, 30*time.Second, time.Minute)
+	clock.Advance(20 * time.Second)
+	if n, err := cache.Incr("counter", 1); err != nil || n != 1 {
+		t.Fatalf("Incr = %v, %v", n, err)
+	}
+	cache.Compute("computed", func(old interface{}, exists bool) (interface{}, bool) {
+		return old.(int) + 1, true
+	})
+	if !cache.CompareAndSwap("swapped", 0, 1) {
+		t.Fatal("CompareAndSwap should succeed")
+	}
+	clock.Advance(20 * time.Second)
+	if _, err := cache.Get("swapped"); err != nil {
+		t.Fatalf("swapped should be kept while it is accessed, err = %v", err)
+	}
+	clock.Advance(21 * time.Second)
+	for _, key := range []string{"counter", "computed", "swapped"} {
+		if cache.Has(key) {
+			t.Fatalf("%v should keep its per-entry TTL", key)
+		}
+	}
+}
+
+func testRefreshAfterWrite(t *testing.T, evT string) {
+	clock := NewFakeClock()
+	var loads int32
+	var fail int32
+	cache :=
+		New(8).
+			EvictType(evT).
+			Clock(clock).
+			RefreshAfterWrite(time.Minute).
+			LoaderFunc(
+				func(key interface{}) (interface{}, error) {
+					if atomic.LoadInt32(&fail) == 1 {
+						return nil, errors.New("loader error")
+					}
+					return atomic.AddInt32(&loads, 1), nil
+				}).
+			Build()
+
+	waitFor := func(expected interface{}) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			if v, _ := cache.GetIfPresent("key"); v == expected {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("value should be refreshed to %v", expected)
+	}
+
+	if v, err := cache.Get("key"); err != nil || v != int32(1) {
+		t.Fatalf("Get = %v, %v", v, err)
+	}
+	clock.Advance(30 * time.Second)
+	if v, _ := cache.Get("key"); v != int32(1) {
+		t.Fatalf("%v != 1", v)
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Fatalf("loader should not be called before refresh, called %v times", n)
+	}
+
+	clock.Advance(time.Minute)
+	if v, _ := cache.Get("key"); v != int32(1) {
+		t.Fatalf("stale value should be returned at once, not %v", v)
+	}
+	waitFor(int32(2))
+
+	atomic.StoreInt32(&fail, 1)
+	clock.Advance(2 * time.Minute)
+	if v, _ := cache.Get("key"); v != int32(2) {
+		t.Fatalf("%v != 2", v)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if v, err := cache.GetIfPresent("key"); err != nil || v != int32(2) {
+		t.Fatalf("old value should be kept when refresh fails, got %v, %v", v, err)
+	}
+}
+
+func testStaleIfError(t *testing.T, evT string) {
+	clock := NewFakeClock()
+	loaderErr := errors.New("loader error")
+	var fail bool
+	cache :=
+		New(8).
+			EvictType(evT).
+			Clock(clock).
+			Expiration(time.Minute).
+			StaleIfError(5 * time.Minute).
+			LoaderFunc(
+				func(key interface{}) (interface{}, error) {
+					if fail {
+						return nil, loaderErr
+					}
+					return "value", nil
+				}).
+			Build()
+
+	if _, err := cache.Get("key"); err != nil {
+		t.Fatalf("err should not be %v", err)
+	}
+	fail = true
+	clock.Advance(2 * time.Minute)
+	v, err := cache.Get("key")
+	if _, ok := err.(*StaleError); !ok || !errors.Is(err, loaderErr) {
+		t.Fatalf("err should be a StaleError wrapping loaderErr, not %v", err)
+	}
+	if v != "value" {
+		t.Fatalf("stale value should be served, not %v", v)
+	}
+	if n := cache.StaleCount(); n != 1 {
+		t.Fatalf("%v != 1", n)
+	}
+
+	clock.Advance(10 * time.Minute)
+	v, err = cache.Get("key")
+	if err != loaderErr || v != nil {
+		t.Fatalf("Get = %v, %v", v, err)
+	}
+
+	fail = false
+	cache.Get("removed")
+	cache.Remove("removed")
+	fail = true
+	if _, err := cache.Get("removed"); err != loaderErr {
+		t.Fatalf("removed key should not be served stale, err = %v", err)
+	}
+}
+
+func testNegativeTTL(t *testing.T, evT string) {
+	clock := NewFakeClock()
+	loaderErr := errors.New("loader error")
+	calls := make(map[interface{}]int)
+	cache :=
+		New(8).
+			EvictType(evT).
+			Clock(clock).
+			NegativeTTL(time.Minute).
+			LoaderFunc(
+				func(key interface{}) (interface{}, error) {
+					calls[key]++
+					if key == "missing" {
+						return nil, KeyNotFoundError
+					}
+					return nil, loaderErr
+				}).
+			Build()
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Get("missing"); err != KeyNotFoundError {
+			t.Fatalf("err should be KeyNotFoundError, not %v", err)
+		}
+		if _, err := cache.Get("broken"); err != loaderErr {
+			t.Fatalf("err should be loaderErr, not %v", err)
+		}
+	}
+	if calls["missing"] != 1 || calls["broken"] != 1 {
+		t.Fatalf("loader should be called once for each key, not %v", calls)
+	}
+	if n := cache.NegativeHitCount(); n != 4 {
+		t.Fatalf("%v != 4", n)
+	}
+	if l := cache.Len(false); l != 0 {
+		t.Fatalf("negative entries should not take capacity, length is %v", l)
+	}
+
+	clock.Advance(2 * time.Minute)
+	cache.Get("missing")
+	if calls["missing"] != 2 {
+		t.Fatalf("loader should be called again after the TTL, called %v times", calls["missing"])
+	}
+
+	cache.Set("missing", "value")
+	if v, err := cache.Get("missing"); err != nil || v != "value" {
+		t.Fatalf("Get = %v, %v", v, err)
+	}
+
+	var loaded int
+	cache =
+		New(8).
+			EvictType(evT).
+			Clock(clock).
+			NegativeTTL(time.Minute).
+			BatchLoaderFunc(
+				func(keys []interface{}) (map[interface{}]interface{}, error) {
+					loaded += len(keys)
+					return map[interface{}]interface{}{"found": "value"}, nil
+				}).
+			Build()
+	for i := 0; i < 3; i++ {
+		m, err := cache.GetMany([]interface{}{"found", "missing"})
+		if err != nil || len(m) != 1 {
+			t.Fatalf("GetMany = %v, %v", m, err)
+		}
+	}
+	if loaded != 2 {
+		t.Fatalf("batch loader should load 2 keys, not %v", loaded)
+	}
+}
+
+func testWeigher(t *testing.T, evT string) {
+	cache :=
+		New(100).
+			EvictType(evT).
+			Weigher(func(key, value interface{}) int64 {
+				return int64(len(value.(string)))
+			}).
+			MaxWeight(10).
+			Build()
+
+	totalWeight := func() int64 {
+		var total int64
+		for _, v := range cache.GetALL(false) {
+			total += int64(len(v.(string)))
+		}
+		return total
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := cache.Set(i, "xxxx"); err != nil {
+			t.Fatalf("err should not be %v", err)
+		}
+		if w := totalWeight(); w > 10 {
+			t.Fatalf("total weight %v exceeds the max weight", w)
+		}
+		if !cache.Has(i) {
+			t.Fatalf("should have %v", i)
+		}
+	}
+	if l := cache.Len(false); l != 2 {
+		t.Fatalf("%v != 2", l)
+	}
+
+	if err := cache.Set("heavy", "xxxxxxxxxxx"); err != ItemTooHeavyError {
+		t.Fatalf("err should be ItemTooHeavyError, not %v", err)
+	}
+	if cache.Has("heavy") {
+		t.Fatal("should not have heavy")
+	}
+
+	if err := cache.Set(9, "xxxxxxxxxx"); err != nil {
+		t.Fatalf("err should not be %v", err)
+	}
+	if l := cache.Len(false); l != 1 {
+		t.Fatalf("%v != 1", l)
+	}
+	if v, _ := cache.Get(9); v != "xxxxxxxxxx" {
+		t.Fatalf("updated item should be kept, got %v", v)
+	}
+}
+
+func testExpireAfterAccess(t *testing.T, evT string) {
+	clock := NewFakeClock()
+	cache :=
+		New(8).
+			EvictType(evT).
+			Clock(clock).
+			ExpireAfterAccess(time.Minute).
+			Expiration(5 * time.Minute).
+			Build()
+
+	cache.Set("key", "value")
+	cache.Set("idle", "value")
+	for i := 0; i < 5; i++ {
+		clock.Advance(50 * time.Second)
+		if _, err := cache.Get("key"); err != nil {
+			t.Fatalf("key should be kept while it is accessed, err = %v", err)
+		}
+	}
+	if cache.Has("idle") {
+		t.Fatal("idle should expire")
+	}
+	clock.Advance(55 * time.Second)
+	if _, err := cache.Get("key"); err != KeyNotFoundError {
+		t.Fatalf("key should expire after the max lifetime, err = %v", err)
+	}
+
+	cache =
+		New(8).
+			EvictType(evT).
+			Clock(clock).
+			Build()
+	cache.SetWithExpireAfterAccess("key", "value", time.Minute)
+	cache.Set("forever", "value")
+	for i := 0; i < 3; i++ {
+		clock.Advance(50 * time.Second)
+		if _, err := cache.Get("key"); err != nil {
+			t.Fatalf("key should be kept while it is accessed, err = %v", err)
+		}
+	}
+	clock.Advance(61 * time.Second)
+	if _, err := cache.Get("key"); err != KeyNotFoundError {
+		t.Fatalf("key should expire, err = %v", err)
+	}
+	if !cache.Has("forever") {
+		t.Fatal("should have forever")
+	}
+
+	// 单独设置的最长存活时间
+	cache.SetWithExpireAfterAccessAndLifetime("capped", "value", time.Minute, 90*time.Second)
+	clock.Advance(50 * time.Second)
+	if _, err := cache.Get("capped"); err != nil {
+		t.Fatalf("capped should be kept while it is accessed, err = %v", err)
+	}
+	clock.Advance(50 * time.Second)
+	if cache.Has("capped") {
+		t.Fatal("capped should expire after its max lifetime")
+	}
+
+	// 重新写入时不保留之前单独设置的过期时间
+	cache.SetWithExpire("rewritten", "value", time.Second)
+	cache.SetWithExpireAfterAccess("rewritten", "value", time.Minute)
+	clock.Advance(50 * time.Second)
+	if _, err := cache.Get("rewritten"); err != nil {
+		t.Fatalf("rewritten should not keep the old deadline, err = %v", err)
+	}
+	cache.Set("rewritten", "value")
+	clock.Advance(2 * time.Minute)
+	if !cache.Has("rewritten") {
+		t.Fatal("rewritten should not keep the old idle time")
+	}
+}
+
+// valueExpiry 的过期时间为 value 分钟 value 为 -1 时不会过期 sliding 每次命中之后可以再存活一分钟
+type valueExpiry struct{}
+
+func (valueExpiry) ExpireAfterCreate(key, value interface{}, now time.Time) time.Duration {
+	if value.(int) < 0 {
+		return NeverExpire
+	}
+	return time.Duration(value.(int)) * time.Minute
+}
+
+func (valueExpiry) ExpireAfterUpdate(key, value interface{}, now time.Time, remaining time.Duration) time.Duration {
+	return remaining
+}
+
+func (valueExpiry) ExpireAfterRead(key, value interface{}, now time.Time, remaining time.Duration) time.Duration {
+	if key == "sliding" {
+		return time.Minute
+	}
+	return remaining
+}
+
+func testExpiry(t *testing.T, evT string) {
+	clock := NewFakeClock()
+	cache :=
+		New(8).
+			EvictType(evT).
+			Clock(clock).
+			Expiry(valueExpiry{}).
+			Expiration(time.Second).
+			Build()
+
+	cache.Set("short", 1)
+	cache.Set("long", 4)
+	cache.Set("forever", -1)
+	cache.Set("sliding", 2)
+	cache.SetWithExpire("override", 3, time.Second)
+
+	clock.Advance(30 * time.Second)
+	// 更新时保留剩余的存活时间
+	cache.Set("short", 10)
+	for i := 0; i < 3; i++ {
+		clock.Advance(50 * time.Second)
+		if _, err := cache.Get("sliding"); err != nil {
+			t.Fatalf("sliding should be kept while it is accessed, err = %v", err)
+		}
+	}
+	if cache.Has("short") {
+		t.Fatal("short should expire")
+	}
+	if cache.Has("override") {
+		t.Fatal("override should expire")
+	}
+	if !cache.Has("long") {
+		t.Fatal("should have long")
+	}
+	clock.Advance(61 * time.Second)
+	if cache.Has("long") || cache.Has("sliding") {
+		t.Fatal("long and sliding should expire")
+	}
+	clock.Advance(time.Hour)
+	if v, err := cache.Get("forever"); err != nil || v != -1 {
+		t.Fatalf("forever should not expire, v = %v, err = %v", v, err)
+	}
+}
+
+func testCleanupInterval(t *testing.T, evT string) {
+	clock := NewFakeClock()
+	evicted := make(chan interface{}, 8)
+	cache :=
+		New(8).
+			EvictType(evT).
+			Clock(clock).
+			CleanupInterval(time.Minute).
+			EvictedFunc(func(key, value interface{}) {
+				evicted <- key
+			}).
+			Build()
+	defer cache.Close()
+
+	cache.SetWithExpire("a", 1, 30*time.Second)
+	cache.SetWithExpire("b", 2, 90*time.Second)
+	cache.Set("forever", 3)
+	for _, key := range []interface{}{"a", "b"} {
+		clock.Advance(time.Minute)
+		select {
+		case k := <-evicted:
+			if k != key {
+				t.Fatalf("%v should be cleaned up, got %v", key, k)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%v should be cleaned up", key)
+		}
+	}
+	if n := cache.Len(false); n != 1 {
+		t.Fatalf("expected 1 item, got %d", n)
+	}
+
+	cache.Close()
+	cache.SetWithExpire("c", 4, time.Second)
+	clock.Advance(time.Minute)
+	select {
+	case k := <-evicted:
+		t.Fatalf("%v should not be cleaned up after Close", k)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if n := cache.Len(false); n != 2 {
+		t.Fatalf("expected 2 items, got %d", n)
+	}
+}
+
+func testEvictExpiredFirst(t *testing.T, evT string) {
+	clock := NewFakeClock()
+	cache :=
+		New(4).
+			EvictType(evT).
+			Clock(clock).
+			Build()
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.SetWithExpire("x", 3, time.Second)
+	cache.SetWithExpire("y", 4, time.Second)
+	for i := 0; i < 3; i++ {
+		cache.Get("a")
+		cache.Get("b")
+	}
+	clock.Advance(2 * time.Second)
+	cache.Set("c", 5)
+	cache.Set("d", 6)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if !cache.Has(key) {
+			t.Fatalf("%v should not be evicted while expired items exist", key)
+		}
+	}
+	if n := cache.RemovalCount(RemovalExpired); n != 2 {
+		t.Fatalf("expected 2 expired removals, got %d", n)
+	}
+	if n := cache.RemovalCount(RemovalSize); n != 0 {
+		t.Fatalf("expected no size evictions, got %d", n)
+	}
+
+	cache.Set("e", 7)
+	if n := cache.RemovalCount(RemovalSize); n != 1 {
+		t.Fatalf("expected 1 size eviction, got %d", n)
+	}
+	cache.Remove("e")
+	if n := cache.RemovalCount(RemovalExplicit); n != 1 {
+		t.Fatalf("expected 1 explicit removal, got %d", n)
+	}
+}
+
+type removal struct {
+	key, value interface{}
+	cause      RemovalCause
+}
+
+func testRemovalListener(t *testing.T, evT string) {
+	clock := NewFakeClock()
+	var removals []removal
+	cache :=
+		New(2).
+			EvictType(evT).
+			Clock(clock).
+			RemovalListener(func(key, value interface{}, cause RemovalCause) {
+				removals = append(removals, removal{key, value, cause})
+			}).
+			Build()
+	last := func(want removal) {
+		t.Helper()
+		if len(removals) == 0 || removals[len(removals)-1] != want {
+			t.Fatalf("expected %v, got %v", want, removals)
+		}
+	}
+
+	cache.Set("a", 1)
+	cache.Set("a", 2)
+	last(removal{"a", 1, RemovalReplaced})
+	cache.Set("b", 3)
+	cache.Set("c", 4)
+	if r := removals[len(removals)-1]; r.cause != RemovalSize || (r.key != "a" && r.key != "b") {
+		t.Fatalf("a or b should be evicted, got %v", r)
+	}
+	cache.Remove("c")
+	last(removal{"c", 4, RemovalExplicit})
+	cache.SetWithExpire("d", 5, time.Second)
+	clock.Advance(2 * time.Second)
+	cache.Get("d")
+	last(removal{"d", 5, RemovalExpired})
+	cache.SetWithExpire("e", 6, time.Second)
+	clock.Advance(2 * time.Second)
+	cache.Set("e", 7)
+	last(removal{"e", 6, RemovalExpired})
+	if n := cache.RemovalCount(RemovalReplaced); n != 1 {
+		t.Fatalf("expected 1 replaced value, got %d", n)
+	}
+}
+
+func testAsyncListeners(t *testing.T, evT string) {
+	var (
+		mu       sync.Mutex
+		removals []removal
+		added    int
+		cache    Cache
+	)
+	release := make(chan struct{})
+	cache =
+		New(8).
+			EvictType(evT).
+			AsyncListeners(1024, FullBlock).
+			AddedFunc(func(key, value interface{}) {
+				<-release
+				mu.Lock()
+				added++
+				mu.Unlock()
+			}).
+			RemovalListener(func(key, value interface{}, cause RemovalCause) {
+				// 在锁外执行 可以再访问缓存
+				cache.Has(key)
+				mu.Lock()
+				removals = append(removals, removal{key, value, cause})
+				mu.Unlock()
+			}).
+			Build()
+
+	for i := 1; i <= 3; i++ {
+		cache.Set("a", i)
+	}
+	// 监听函数阻塞时不会影响其他的操作
+	if v, err := cache.Get("a"); err != nil || v != 3 {
+		t.Fatalf("expected 3, got %v, err = %v", v, err)
+	}
+	cache.Remove("a")
+	close(release)
+	cache.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if added != 3 {
+		t.Fatalf("expected 3 added events, got %d", added)
+	}
+	want := []removal{
+		{"a", 1, RemovalReplaced},
+		{"a", 2, RemovalReplaced},
+		{"a", 3, RemovalExplicit},
+	}
+	if len(removals) != len(want) {
+		t.Fatalf("expected %v, got %v", want, removals)
+	}
+	for i := range want {
+		if removals[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, removals)
+		}
+	}
+}
+
+func testPurge(t *testing.T, evT string) {
+	clock := NewFakeClock()
+	visited := map[interface{}]interface{}{}
+	var cleared int
+	var cache Cache
+	cache =
+		New(8).
+			EvictType(evT).
+			Clock(clock).
+			Weigher(func(key, value interface{}) int64 { return 1 }).
+			MaxWeight(8).
+			RemovalListener(func(key, value interface{}, cause RemovalCause) {
+				if cause == RemovalCleared {
+					cleared++
+				}
+			}).
+			PurgeVisitorFunc(func(key, value interface{}) {
+				// 在锁外调用 可以再访问缓存
+				if cache.Has(key) {
+					t.Errorf("%v should be purged", key)
+				}
+				visited[key] = value
+			}).
+			Build()
+
+	setItemsByRange(t, cache, 0, 6)
+	cache.SetWithExpire("dead", 6, time.Second)
+	cache.Get(0)
+	clock.Advance(2 * time.Second)
+	cache.Purge()
+
+	if n := cache.Len(false); n != 0 {
+		t.Fatalf("expected empty cache, got %d items", n)
+	}
+	if len(visited) != 6 || cleared != 6 {
+		t.Fatalf("expected 6 live items, visited %v, cleared %d", visited, cleared)
+	}
+	if _, ok := visited["dead"]; ok {
+		t.Fatal("expired items should not be visited")
+	}
+	if hc := cache.HitCount(); hc != 1 {
+		t.Fatalf("stats should be kept, hit count %d", hc)
+	}
+
+	// 清空之后可以正常使用
+	setItemsByRange(t, cache, 0, 20)
+	if n := cache.Len(false); n != 8 {
+		t.Fatalf("expected 8 items, got %d", n)
+	}
+	for i := 12; i < 20; i++ {
+		cache.Get(i)
+	}
+	if _, err := cache.Get(19); err != nil {
+		t.Fatalf("the last item should be kept, err = %v", err)
+	}
+}
+
+func testStats(t *testing.T, evT string) {
+	cache :=
+		New(4).
+			EvictType(evT).
+			Weigher(func(key, value interface{}) int64 { return 2 }).
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				if key == "bad" {
+					return nil, errors.New("load failed")
+				}
+				return key, nil
+			}).
+			Build()
+
+	cache.Get("a")
+	cache.Get("bad")
+	setItemsByRange(t, cache, 0, 5)
+	cache.Remove(4)
+	snap := cache.Stats()
+	if snap.LoadSuccessCount != 1 || snap.LoadFailureCount != 1 {
+		t.Fatalf("expected 1 successful and 1 failed load, got %+v", snap)
+	}
+	if snap.AverageLoadPenalty() != snap.TotalLoadTime/2 {
+		t.Fatalf("average load penalty %v, total load time %v", snap.AverageLoadPenalty(), snap.TotalLoadTime)
+	}
+	if snap.RemovalCount(RemovalSize) != 2 || snap.RemovalCount(RemovalExplicit) != 1 || snap.EvictionCount() != 2 {
+		t.Fatalf("unexpected removals %v", snap.Removals)
+	}
+	if snap.EntryCount != 3 || snap.Weight != 6 {
+		t.Fatalf("expected 3 items weighing 6, got %d items weighing %d", snap.EntryCount, snap.Weight)
+	}
+
+	key := cache.Keys(false)[0]
+	cache.Get(key)
+	cache.Get(key)
+	cache.Get("b")
+	d := cache.Stats().Minus(snap)
+	if d.HitCount != 2 || d.LoadSuccessCount != 1 || d.LoadFailureCount != 0 || d.EvictionCount() != 0 {
+		t.Fatalf("unexpected delta %+v", d)
+	}
+	if d.EntryCount != 4 || d.Weight != 8 {
+		t.Fatalf("delta should keep the current size, got %d items weighing %d", d.EntryCount, d.Weight)
+	}
+	// 快照不会随着缓存变化
+	cache.Get(key)
+	if snap.HitCount == cache.Stats().HitCount {
+		t.Fatal("snapshot should not change")
+	}
+}
+
 func setItemsByRange(t *testing.T, c Cache, start, end int) {
 	for i := start; i < end; i++ {
 		if err := c.Set(i, i); err != nil {
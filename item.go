@@ -0,0 +1,51 @@
+package hyliocache
+
+import "time"
+
+// cacheItem 保存各个淘汰策略的元素共有的信息
+type cacheItem struct {
+	clock      Clock
+	key        interface{}
+	value      interface{}
+	expiration *time.Time     // 实际的过期时间
+	deadline   *time.Time     // 最长的存活时间
+	idle       *time.Duration // 多久没有被访问之后过期
+	writeTime  time.Time      // 最近一次写入的时间
+	written    bool           // 是否已经被写入过
+	weight     int64
+	cost       float64 // 重新加载这个元素的代价
+	timers     *expiryQueue
+	timer      int // 在 timers 中的位置加一 0 表示不在其中
+}
+
+func (it *cacheItem) IsExpired(now *time.Time) bool {
+	if it.expiration == nil {
+		return false
+	}
+	if now == nil {
+		t := it.clock.Now()
+		now = &t
+	}
+	return it.expiration.Before(*now)
+}
+
+// expireAt 设置元素最长的存活时间
+func (it *cacheItem) expireAt(deadline, now time.Time) {
+	it.deadline = &deadline
+	it.touch(now)
+}
+
+// touch 元素被访问时重新计算过期时间
+// 设置了 idle 时从 now 开始计算 但不会晚于 deadline
+func (it *cacheItem) touch(now time.Time) {
+	defer it.timers.fix(it)
+	if it.idle == nil {
+		it.expiration = it.deadline
+		return
+	}
+	t := now.Add(*it.idle)
+	if it.deadline != nil && it.deadline.Before(t) {
+		t = *it.deadline
+	}
+	it.expiration = &t
+}
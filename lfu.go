@@ -2,20 +2,26 @@ package hyliocache
 
 import (
 	"container/list"
+	"sort"
 	"time"
 )
 
 type LFUCache struct {
 	baseCache
-	items    map[interface{}]*lfuItem
-	freqList *list.List
+	items     map[interface{}]*lfuItem
+	freqList  *list.List
+	tick      uint64         // 每次写入或者访问时加一 用于同一频率内的排序
+	decay     *time.Duration // 访问频率减半的周期
+	lastDecay time.Time
 }
 
 func newLFUCache(cb *CacheBuilder) *LFUCache {
-	c := &LFUCache{}
+	c := &LFUCache{decay: cb.frequencyDecay}
 	buildCache(&c.baseCache, cb)
 	c.init()
 	c.group.cache = c
+	c.store = c
+	c.startJanitor(cb.cleanupInterval)
 	return c
 }
 
@@ -24,131 +30,72 @@ func (L *LFUCache) init() {
 	L.items = make(map[interface{}]*lfuItem)
 	L.freqList.PushFront(&freqEntry{
 		freq:  0,
-		items: make(map[*lfuItem]struct{}),
+		items: list.New(),
 	})
+	L.tick = 0
+	L.lastDecay = L.clock.Now()
 }
 
-func (L *LFUCache) Set(key, value interface{}) error {
-	L.mu.Lock()
-	defer L.mu.Unlock()
-	_, err := L.set(key, value)
-	return err
-}
-
-func (L *LFUCache) SetWithExpire(key, value interface{}, expiration time.Duration) error {
-	L.mu.Lock()
-	defer L.mu.Unlock()
-	item, err := L.set(key, value)
+func (L *LFUCache) set(key, value interface{}) (*cacheItem, error) {
+	w, err := L.weigh(key, value)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	t := L.clock.Now().Add(expiration)
-	item.(*lfuItem).expiration = &t
-	return nil
-}
-
-func (L *LFUCache) set(key, value interface{}) (interface{}, error) {
+	L.maybeDecay()
 	item, ok := L.items[key]
 	if ok {
-		item.value = value
+		L.replaceValue(&item.cacheItem, value)
 	} else {
 		if len(L.items) >= L.size {
 			L.evict(1)
 		}
 		item = &lfuItem{
-			clock:       L.clock,
-			key:         key,
-			value:       value,
+			cacheItem:   L.newItem(key, value),
 			freqElement: nil,
 		}
-		// 直接加到了freqList的最前面
-		// todo: WHY
-		head := L.freqList.Front()
-		entry := head.Value.(*freqEntry)
-		entry.items[item] = struct{}{}
-		item.freqElement = head
+		// 新的元素的频率为 0 放在 freqList 最前面的结点中
+		L.pushItem(L.freqList.Front(), item)
 		L.items[key] = item
 	}
-	if L.expiration != nil {
-		t := L.clock.Now().Add(*L.expiration)
-		item.expiration = &t
-	}
-	if L.addedFunc != nil {
-		L.addedFunc(key, value)
-	}
-	return item, nil
+	L.written(&item.cacheItem)
+	L.setWeight(&item.cacheItem, w)
+	L.evictOverweight(item)
+	L.added(key, value)
+	return &item.cacheItem, nil
 }
 
-func (L *LFUCache) Get(key interface{}) (interface{}, error) {
-	v, err := L.get(key, false)
-	if err == KeyNotFoundError {
-		return L.getWithLoader(key, true)
-	}
-	return v, err
-}
-
-func (L *LFUCache) GetIfPresent(key interface{}) (interface{}, error) {
-	v, err := L.get(key, false)
-	if err == KeyNotFoundError {
-		return L.getWithLoader(key, false)
+// lookup 查找未过期的元素 调用时需持有 mu
+func (L *LFUCache) lookup(key interface{}) (*cacheItem, bool) {
+	L.maybeDecay()
+	item, ok := L.items[key]
+	if ok {
+		if !item.IsExpired(nil) {
+			L.increment(item)
+			return &item.cacheItem, true
+		}
+		L.keepStale(&item.cacheItem)
+		L.removeItem(item, RemovalExpired)
 	}
-	return v, err
+	return nil, false
 }
 
-func (L *LFUCache) get(key interface{}, onLoad bool) (interface{}, error) {
-	v, err := L.getValue(key, onLoad)
-	if err != nil {
-		return nil, err
+// peek 返回 key 对应的元素 不会删除过期的元素 调用时需持有 mu
+func (L *LFUCache) peek(key interface{}) (*cacheItem, bool) {
+	item, ok := L.items[key]
+	if !ok {
+		return nil, false
 	}
-	return v, nil
+	return &item.cacheItem, true
 }
 
-func (L *LFUCache) getWithLoader(key interface{}, isWait bool) (interface{}, error) {
-	if L.loaderExpireFunc == nil {
-		return nil, KeyNotFoundError
-	}
-	value, _, err := L.load(key, func(v interface{}, expiration *time.Duration, e error) (interface{}, error) {
-		if e != nil {
-			return nil, e
-		}
-		L.mu.Lock()
-		defer L.mu.Unlock()
-		item, err := L.set(key, v)
-		if err != nil {
-			return nil, err
-		}
-		if expiration != nil {
-			t := L.clock.Now().Add(*expiration)
-			item.(*lfuItem).expiration = &t
-		}
-		return v, nil
-	}, isWait)
-	if err != nil {
-		return nil, err
+func (L *LFUCache) each(fn func(item *cacheItem)) {
+	for _, item := range L.items {
+		fn(&item.cacheItem)
 	}
-	return value, nil
 }
 
-func (L *LFUCache) getValue(key interface{}, onLoad bool) (interface{}, error) {
-	L.mu.Lock()
-	item, ok := L.items[key]
-	if ok {
-		if !item.IsExpired(nil) {
-			L.increment(item)
-			v := item.value
-			L.mu.Unlock()
-			if !onLoad {
-				L.stats.IncrHitCount()
-			}
-			return v, nil
-		}
-		L.removeItem(item)
-	}
-	L.mu.Unlock()
-	if !onLoad {
-		L.stats.IncrMissCount()
-	}
-	return nil, KeyNotFoundError
+func (L *LFUCache) length() int {
+	return len(L.items)
 }
 
 // increment 增加item的freq
@@ -156,7 +103,7 @@ func (L *LFUCache) increment(item *lfuItem) {
 	currentFreqElement := item.freqElement
 	currentFreqEntry := currentFreqElement.Value.(*freqEntry)
 	nextFreq := currentFreqEntry.freq + 1
-	delete(currentFreqEntry.items, item)
+	currentFreqEntry.items.Remove(item.element)
 
 	removable := isRemovableFreqEntry(currentFreqEntry)
 	nextFreqElement := currentFreqElement.Next()
@@ -171,7 +118,7 @@ func (L *LFUCache) increment(item *lfuItem) {
 		} else {
 			nextFreqElement = L.freqList.InsertAfter(&freqEntry{
 				freq:  nextFreq,
-				items: make(map[*lfuItem]struct{}),
+				items: list.New(),
 			}, currentFreqElement)
 		}
 	case nextFreqElement.Value.(*freqEntry).freq == nextFreq:
@@ -183,137 +130,135 @@ func (L *LFUCache) increment(item *lfuItem) {
 	default:
 		panic("LFU freq element unreachable")
 	}
-	nextFreqElement.Value.(*freqEntry).items[item] = struct{}{}
-	item.freqElement = nextFreqElement
+	L.pushItem(nextFreqElement, item)
 }
 
-func (L *LFUCache) Keys(checkExpired bool) []interface{} {
-	L.mu.RLock()
-	defer L.mu.RUnlock()
-	keys := make([]interface{}, 0, len(L.items))
-	now := time.Now()
-	for key := range L.items {
-		if !checkExpired || L.has(key, &now) {
-			keys = append(keys, key)
-		}
+// pushItem 把 item 放到频率结点 e 的最前面
+// 同一频率内越靠后的元素越久没有被访问 会先被淘汰
+func (L *LFUCache) pushItem(e *list.Element, item *lfuItem) {
+	L.tick++
+	item.tick = L.tick
+	item.freqElement = e
+	item.element = e.Value.(*freqEntry).items.PushFront(item)
+}
+
+// maybeDecay 每经过一个 decay 周期 所有元素的访问频率减半
+func (L *LFUCache) maybeDecay() {
+	if L.decay == nil || *L.decay <= 0 {
+		return
 	}
-	return keys
+	now := L.clock.Now()
+	n := now.Sub(L.lastDecay) / *L.decay
+	if n <= 0 {
+		return
+	}
+	L.lastDecay = L.lastDecay.Add(n * *L.decay)
+	L.decayFreq(uint(min(int(n), 63)))
 }
 
-func (L *LFUCache) Remove(key interface{}) bool {
-	L.mu.Lock()
-	defer L.mu.Unlock()
-	return L.remove(key)
+// decayFreq 把所有元素的访问频率右移 shift 位
+// 频率相同的结点合并后 仍然按照最近访问的顺序排列
+func (L *LFUCache) decayFreq(shift uint) {
+	freqList := list.New()
+	var merged []*lfuItem
+	flush := func() {
+		if len(merged) == 0 {
+			return
+		}
+		sort.Slice(merged, func(i, j int) bool { return merged[i].tick > merged[j].tick })
+		e := freqList.Back()
+		entry := e.Value.(*freqEntry)
+		for _, item := range merged {
+			item.freqElement = e
+			item.element = entry.items.PushBack(item)
+		}
+		merged = merged[:0]
+	}
+	for e := L.freqList.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*freqEntry)
+		freq := entry.freq >> shift
+		if back := freqList.Back(); back == nil || back.Value.(*freqEntry).freq != freq {
+			flush()
+			freqList.PushBack(&freqEntry{freq: freq, items: list.New()})
+		}
+		for ie := entry.items.Front(); ie != nil; ie = ie.Next() {
+			merged = append(merged, ie.Value.(*lfuItem))
+		}
+	}
+	flush()
+	L.freqList = freqList
 }
 
-func (L *LFUCache) remove(key interface{}) bool {
+func (L *LFUCache) remove(key interface{}, cause RemovalCause) bool {
 	if item, ok := L.items[key]; ok {
-		L.removeItem(item)
+		L.removeItem(item, cause)
 		return true
 	}
 	return false
 }
 
-func (L *LFUCache) GetALL(checkExpired bool) map[interface{}]interface{} {
-	L.mu.RLock()
-	defer L.mu.RUnlock()
-	items := make(map[interface{}]interface{}, len(L.items))
-	now := time.Now()
-	for k, item := range L.items {
-		if !checkExpired || L.has(k, &now) {
-			items[k] = item.value
+func (L *LFUCache) evict(count int) {
+	count -= L.removeExpired()
+	for i := 0; i < count; i++ {
+		item := L.victim(nil)
+		if item == nil {
+			return
 		}
+		L.removeItem(item, RemovalSize)
 	}
-	return items
 }
 
-func (L *LFUCache) Len(checkExpired bool) int {
-	L.mu.RLock()
-	defer L.mu.RUnlock()
-	if !checkExpired {
-		return len(L.items)
-	}
-	length := 0
-	now := time.Now()
-	for _, item := range L.items {
-		if !item.IsExpired(&now) {
-			length++
+// victim 返回频率最低的元素中最久没有被访问的 protect 以外的元素
+func (L *LFUCache) victim(protect *lfuItem) *lfuItem {
+	for e := L.freqList.Front(); e != nil; e = e.Next() {
+		for ie := e.Value.(*freqEntry).items.Back(); ie != nil; ie = ie.Prev() {
+			if item := ie.Value.(*lfuItem); item != protect {
+				return item
+			}
 		}
 	}
-	return length
-}
-
-func (L *LFUCache) Has(key interface{}) bool {
-	L.mu.Lock()
-	defer L.mu.Unlock()
-	now := time.Now()
-	return L.has(key, &now)
+	return nil
 }
 
-func (L *LFUCache) has(key interface{}, now *time.Time) bool {
-	item, ok := L.items[key]
-	if !ok {
-		return false
+// evictOverweight 从频率最低的元素开始淘汰 protect 以外的元素
+// 直到总重量不超过 maxWeight
+func (L *LFUCache) evictOverweight(protect *lfuItem) {
+	if !L.overweight() {
+		return
 	}
-	return !item.IsExpired(now)
-}
-
-func (L *LFUCache) evict(count int) {
-	entry := L.freqList.Front()
-	for i := 0; i < count; {
-		if entry == nil {
+	L.removeExpired()
+	for L.overweight() {
+		item := L.victim(protect)
+		if item == nil {
 			return
-		} else {
-			for item := range entry.Value.(*freqEntry).items {
-				// 同一个优先级随机淘汰
-				if i >= count {
-					return
-				}
-				L.removeItem(item)
-				i++
-			}
-			entry = entry.Next()
 		}
+		L.removeItem(item, RemovalSize)
 	}
 }
 
-func (L *LFUCache) removeItem(item *lfuItem) {
+func (L *LFUCache) removeItem(item *lfuItem, cause RemovalCause) {
 	entry := item.freqElement.Value.(*freqEntry)
 	delete(L.items, item.key)
-	delete(entry.items, item)
+	entry.items.Remove(item.element)
 	if isRemovableFreqEntry(entry) {
 		L.freqList.Remove(item.freqElement)
 	}
-	if L.evictedFunc != nil {
-		L.evictedFunc(item.key, item.value)
-	}
+	L.removed(&item.cacheItem, cause)
 }
 
 type lfuItem struct {
-	clock       Clock
-	key         interface{} // ?
-	value       interface{}
-	freqElement *list.Element
-	expiration  *time.Time
-}
-
-func (it *lfuItem) IsExpired(now *time.Time) bool {
-	if it.expiration == nil {
-		return false
-	}
-	if now == nil {
-		t := it.clock.Now()
-		now = &t
-	}
-	return it.expiration.Before(*now)
+	cacheItem
+	freqElement *list.Element // 所在的频率结点
+	element     *list.Element // 在频率结点中的位置
+	tick        uint64
 }
 
 type freqEntry struct {
 	freq  uint
-	items map[*lfuItem]struct{}
+	items *list.List // 越靠前的元素越晚被访问
 }
 
 // isRemovableFreqEntry 判断一个entry是否已经没用了
 func isRemovableFreqEntry(entry *freqEntry) bool {
-	return entry.freq != 0 && len(entry.items) == 0
+	return entry.freq != 0 && entry.items.Len() == 0
 }
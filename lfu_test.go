@@ -51,6 +51,66 @@ func TestLFUGetIFPresent(t *testing.T) {
 	testGetIFPresent(t, TypeLfu)
 }
 
+func TestLFUGetCtx(t *testing.T) {
+	testGetCtx(t, TypeLfu)
+}
+
+func TestLFUGetMany(t *testing.T) {
+	testGetMany(t, TypeLfu)
+}
+
+func TestLFUCompute(t *testing.T) {
+	testCompute(t, TypeLfu)
+}
+
+func TestLFURefreshAfterWrite(t *testing.T) {
+	testRefreshAfterWrite(t, TypeLfu)
+}
+
+func TestLFUStaleIfError(t *testing.T) {
+	testStaleIfError(t, TypeLfu)
+}
+
+func TestLFUNegativeTTL(t *testing.T) {
+	testNegativeTTL(t, TypeLfu)
+}
+
+func TestLFUWeigher(t *testing.T) {
+	testWeigher(t, TypeLfu)
+}
+
+func TestLFUExpireAfterAccess(t *testing.T) {
+	testExpireAfterAccess(t, TypeLfu)
+}
+
+func TestLFUExpiry(t *testing.T) {
+	testExpiry(t, TypeLfu)
+}
+
+func TestLFUCleanupInterval(t *testing.T) {
+	testCleanupInterval(t, TypeLfu)
+}
+
+func TestLFUEvictExpiredFirst(t *testing.T) {
+	testEvictExpiredFirst(t, TypeLfu)
+}
+
+func TestLFURemovalListener(t *testing.T) {
+	testRemovalListener(t, TypeLfu)
+}
+
+func TestLFUAsyncListeners(t *testing.T) {
+	testAsyncListeners(t, TypeLfu)
+}
+
+func TestLFUPurge(t *testing.T) {
+	testPurge(t, TypeLfu)
+}
+
+func TestLFUStats(t *testing.T) {
+	testStats(t, TypeLfu)
+}
+
 func TestLFUHas(t *testing.T) {
 	gc := buildTestLoadingCacheWithExpiration(t, TypeLfu, 2, 10*time.Millisecond)
 
@@ -194,3 +254,68 @@ func TestLFUFreqListLength(t *testing.T) {
 		}
 	}
 }
+
+func TestLFUEvictLeastRecentlyUsed(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		gc := buildTestCache(t, TypeLfu, 3)
+		gc.Set(0, 0)
+		gc.Set(1, 1)
+		gc.Set(2, 2)
+		// 同一频率内先淘汰最久没有被访问的元素
+		gc.Get(1)
+		gc.Get(0)
+		gc.Set(3, 3)
+		if gc.Has(2) {
+			t.Fatal("2 should be evicted")
+		}
+		gc.Set(4, 4)
+		if gc.Has(3) {
+			t.Fatal("3 should be evicted")
+		}
+		gc.Set(5, 5)
+		if !gc.Has(0) || !gc.Has(1) {
+			t.Fatal("0 and 1 should not be evicted")
+		}
+	}
+}
+
+func TestLFUFrequencyDecay(t *testing.T) {
+	clock := NewFakeClock()
+	gc := New(3).
+		LFU().
+		Clock(clock).
+		FrequencyDecay(time.Hour).
+		Build()
+	gc.Set("old", 1)
+	for i := 0; i < 8; i++ {
+		gc.Get("old")
+	}
+	// 经过三个周期 old 的频率从 8 降到 1
+	clock.Advance(3 * time.Hour)
+	gc.Set("new", 2)
+	for i := 0; i < 3; i++ {
+		gc.Get("new")
+	}
+	var freq uint
+	for e := gc.(*LFUCache).freqList.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*freqEntry)
+		for ie := entry.items.Front(); ie != nil; ie = ie.Next() {
+			if ie.Value.(*lfuItem).key == "old" {
+				freq = entry.freq
+			}
+		}
+	}
+	if freq != 1 {
+		t.Fatalf("%v != 1", freq)
+	}
+	gc.Set("a", 3)
+	gc.Get("a")
+	gc.Get("a")
+	gc.Set("b", 4)
+	if gc.Has("old") {
+		t.Fatal("old should be evicted after its frequency decays")
+	}
+	if !gc.Has("new") || !gc.Has("a") || !gc.Has("b") {
+		t.Fatal("new keys should not be evicted")
+	}
+}
@@ -0,0 +1,202 @@
+package hyliocache
+
+import "container/list"
+
+/*
+lirs 模块实现 LIRS 淘汰策略
+元素按照重用距离分为 LIR 和 HIR 两类 只有驻留的 HIR 元素会被淘汰
+栈 S 按照访问顺序记录 LIR 元素 以及最近访问过的 HIR 元素 (包括已经被淘汰的)
+队列 Q 记录所有驻留的 HIR 元素 淘汰时从 Q 的队首开始
+在 S 中的 HIR 元素再次被访问时 说明它的重用距离比 S 底部的 LIR 元素短 升级为 LIR
+循环访问比缓存更大的范围时 LIR 元素不会被挤出缓存
+*/
+
+// DefaultHIRRatio 驻留的 HIR 元素占容量的比例
+const DefaultHIRRatio = 0.01
+
+const (
+	lirsLIR = iota
+	lirsHIR
+	lirsNonResident // 已经被淘汰但仍然在 S 中的 HIR 元素
+)
+
+type lirsNode struct {
+	key    interface{}
+	status int
+	stack  *list.Element // 在 S 中的位置
+	queue  *list.Element // 驻留时在 Q 中的位置 非驻留时在 nonResident 中的位置
+}
+
+type lirs struct {
+	nodes          map[interface{}]*lirsNode
+	stack          *list.List // 栈顶在最前端
+	queue          *list.List
+	nonResident    *list.List
+	lirCap         int
+	lirCount       int
+	nonResidentCap int
+}
+
+func newLIRS(size int, hirRatio float64) *lirs {
+	hirCap := max(1, int(float64(size)*hirRatio))
+	return &lirs{
+		nodes:          make(map[interface{}]*lirsNode, size),
+		stack:          list.New(),
+		queue:          list.New(),
+		nonResident:    list.New(),
+		lirCap:         max(1, size-hirCap),
+		nonResidentCap: size,
+	}
+}
+
+func (p *lirs) OnInsert(key interface{}) {
+	if n, ok := p.nodes[key]; ok {
+		// 非驻留的 HIR 元素再次被写入时升级为 LIR
+		p.nonResident.Remove(n.queue)
+		n.queue = nil
+		p.promote(n)
+		return
+	}
+	n := &lirsNode{key: key}
+	p.nodes[key] = n
+	if p.lirCount < p.lirCap {
+		n.status = lirsLIR
+		p.lirCount++
+		p.pushStack(n)
+		return
+	}
+	n.status = lirsHIR
+	p.pushStack(n)
+	p.pushQueue(n)
+}
+
+func (p *lirs) OnAccess(key interface{}) {
+	n, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	switch n.status {
+	case lirsLIR:
+		bottom := p.stack.Back() == n.stack
+		p.pushStack(n)
+		if bottom {
+			p.prune()
+		}
+	case lirsHIR:
+		if n.stack != nil {
+			p.queue.Remove(n.queue)
+			n.queue = nil
+			p.promote(n)
+		} else {
+			p.pushStack(n)
+			p.pushQueue(n)
+		}
+	}
+}
+
+// OnRemove 刚被 Victim 淘汰的元素作为非驻留的 HIR 元素留在 S 中
+func (p *lirs) OnRemove(key interface{}) {
+	n, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	switch n.status {
+	case lirsNonResident:
+		return
+	case lirsLIR:
+		p.lirCount--
+	case lirsHIR:
+		p.queue.Remove(n.queue)
+	}
+	p.removeStack(n)
+	delete(p.nodes, key)
+	p.prune()
+}
+
+// Victim 淘汰 Q 队首的 HIR 元素 Q 为空时先把 S 底部的 LIR 元素降级
+func (p *lirs) Victim() (interface{}, bool) {
+	if p.queue.Len() == 0 {
+		if p.lirCount == 0 {
+			return nil, false
+		}
+		p.demote()
+	}
+	n := p.queue.Front().Value.(*lirsNode)
+	p.queue.Remove(n.queue)
+	n.queue = nil
+	if n.stack == nil {
+		delete(p.nodes, n.key)
+		return n.key, true
+	}
+	n.status = lirsNonResident
+	n.queue = p.nonResident.PushBack(n)
+	// 非驻留的元素最多记录 nonResidentCap 个
+	for p.nonResident.Len() > p.nonResidentCap {
+		old := p.nonResident.Remove(p.nonResident.Front()).(*lirsNode)
+		p.removeStack(old)
+		delete(p.nodes, old.key)
+	}
+	return n.key, true
+}
+
+// promote 把 n 升级为 LIR 超出 LIR 的容量时降级 S 底部的 LIR 元素
+func (p *lirs) promote(n *lirsNode) {
+	n.status = lirsLIR
+	p.lirCount++
+	p.pushStack(n)
+	if p.lirCount > p.lirCap {
+		p.demote()
+	}
+}
+
+// demote 把 S 底部的 LIR 元素降级为 HIR 并放到 Q 的末端
+func (p *lirs) demote() {
+	e := p.stack.Back()
+	if e == nil {
+		return
+	}
+	n := e.Value.(*lirsNode)
+	p.removeStack(n)
+	n.status = lirsHIR
+	p.lirCount--
+	p.pushQueue(n)
+	p.prune()
+}
+
+// prune 删除 S 底部的 HIR 元素 保证 S 的底部是 LIR 元素
+func (p *lirs) prune() {
+	for e := p.stack.Back(); e != nil; e = p.stack.Back() {
+		n := e.Value.(*lirsNode)
+		if n.status == lirsLIR {
+			return
+		}
+		p.removeStack(n)
+		if n.status == lirsNonResident {
+			p.nonResident.Remove(n.queue)
+			delete(p.nodes, n.key)
+		}
+	}
+}
+
+func (p *lirs) pushStack(n *lirsNode) {
+	if n.stack != nil {
+		p.stack.MoveToFront(n.stack)
+		return
+	}
+	n.stack = p.stack.PushFront(n)
+}
+
+func (p *lirs) removeStack(n *lirsNode) {
+	if n.stack != nil {
+		p.stack.Remove(n.stack)
+		n.stack = nil
+	}
+}
+
+func (p *lirs) pushQueue(n *lirsNode) {
+	if n.queue != nil {
+		p.queue.MoveToBack(n.queue)
+		return
+	}
+	n.queue = p.queue.PushBack(n)
+}
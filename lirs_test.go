@@ -0,0 +1,190 @@
+package hyliocache
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestLIRSGet(t *testing.T) {
+	size := 1000
+	gc := buildTestCache(t, TypeLIRS, size)
+	testSetCache(t, gc, size)
+	testGetCache(t, gc, size)
+}
+
+func TestLoadingLIRSGet(t *testing.T) {
+	size := 1000
+	gc := buildTestLoadingCache(t, TypeLIRS, size, loader)
+	testGetCache(t, gc, size)
+}
+
+func TestLIRSLength(t *testing.T) {
+	gc := buildTestLoadingCache(t, TypeLIRS, 1000, loader)
+	gc.Get("test1")
+	gc.Get("test2")
+	length := gc.Len(true)
+	expectedLength := 2
+	if length != expectedLength {
+		t.Errorf("Expected length is %v, not %v", length, expectedLength)
+	}
+}
+
+func TestLIRSEvictItem(t *testing.T) {
+	cacheSize := 10
+	numbers := 11
+	gc := buildTestLoadingCache(t, TypeLIRS, cacheSize, loader)
+
+	for i := 0; i < numbers; i++ {
+		_, err := gc.Get(fmt.Sprintf("Key-%d", i))
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func TestLIRSGetIFPresent(t *testing.T) {
+	testGetIFPresent(t, TypeLIRS)
+}
+
+func TestLIRSGetCtx(t *testing.T) {
+	testGetCtx(t, TypeLIRS)
+}
+
+func TestLIRSGetMany(t *testing.T) {
+	testGetMany(t, TypeLIRS)
+}
+
+func TestLIRSCompute(t *testing.T) {
+	testCompute(t, TypeLIRS)
+}
+
+func TestLIRSRefreshAfterWrite(t *testing.T) {
+	testRefreshAfterWrite(t, TypeLIRS)
+}
+
+func TestLIRSStaleIfError(t *testing.T) {
+	testStaleIfError(t, TypeLIRS)
+}
+
+func TestLIRSNegativeTTL(t *testing.T) {
+	testNegativeTTL(t, TypeLIRS)
+}
+
+func TestLIRSWeigher(t *testing.T) {
+	testWeigher(t, TypeLIRS)
+}
+
+func TestLIRSExpireAfterAccess(t *testing.T) {
+	testExpireAfterAccess(t, TypeLIRS)
+}
+
+func TestLIRSExpiry(t *testing.T) {
+	testExpiry(t, TypeLIRS)
+}
+
+func TestLIRSCleanupInterval(t *testing.T) {
+	testCleanupInterval(t, TypeLIRS)
+}
+
+func TestLIRSEvictExpiredFirst(t *testing.T) {
+	testEvictExpiredFirst(t, TypeLIRS)
+}
+
+func TestLIRSRemovalListener(t *testing.T) {
+	testRemovalListener(t, TypeLIRS)
+}
+
+func TestLIRSAsyncListeners(t *testing.T) {
+	testAsyncListeners(t, TypeLIRS)
+}
+
+func TestLIRSPurge(t *testing.T) {
+	testPurge(t, TypeLIRS)
+}
+
+func TestLIRSStats(t *testing.T) {
+	testStats(t, TypeLIRS)
+}
+
+func TestLIRSHas(t *testing.T) {
+	gc := buildTestLoadingCacheWithExpiration(t, TypeLIRS, 2, 10*time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			gc.Get("test1")
+			gc.Get("test2")
+
+			if gc.Has("test0") {
+				t.Fatal("should not have test0")
+			}
+			if !gc.Has("test1") {
+				t.Fatal("should have test1")
+			}
+			if !gc.Has("test2") {
+				t.Fatal("should have test2")
+			}
+
+			time.Sleep(20 * time.Millisecond)
+
+			if gc.Has("test0") {
+				t.Fatal("should not have test0")
+			}
+			if gc.Has("test1") {
+				t.Fatal("should not have test1")
+			}
+			if gc.Has("test2") {
+				t.Fatal("should not have test2")
+			}
+		})
+	}
+}
+
+func TestLIRSLoop(t *testing.T) {
+	size := 10
+	for _, tp := range []string{TypeLru, TypeLIRS} {
+		gc := New(size).
+			EvictType(tp).
+			HIRRatio(0.2).
+			LoaderFunc(loader).
+			Build()
+		// 循环访问比缓存更大的范围
+		for i := 0; i < 10; i++ {
+			for j := 0; j < 15; j++ {
+				gc.Get(j)
+			}
+		}
+		hits := gc.HitCount()
+		if tp == TypeLru && hits != 0 {
+			t.Fatalf("LRU should never hit, hit %v times", hits)
+		}
+		if tp == TypeLIRS && hits < 60 {
+			t.Fatalf("LIR keys should stay in the cache, hit only %v times", hits)
+		}
+	}
+}
+
+func TestLIRSRandomOps(t *testing.T) {
+	size := 10
+	gc := New(size).EvictType(TypeLIRS).Build()
+	p := gc.(*policyCache).policy.(*lirs)
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		key := rnd.Intn(3 * size)
+		switch rnd.Intn(4) {
+		case 0, 1:
+			gc.Set(key, key)
+		case 2:
+			gc.Get(key)
+		case 3:
+			gc.Remove(key)
+		}
+		if l := gc.Len(false); l > size || l != p.lirCount+p.queue.Len() {
+			t.Fatalf("length %v, LIR %v, HIR %v", l, p.lirCount, p.queue.Len())
+		}
+		if p.nonResident.Len() > size {
+			t.Fatalf("%v > %v", p.nonResident.Len(), size)
+		}
+	}
+}
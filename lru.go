@@ -1,9 +1,6 @@
 package hyliocache
 
-import (
-	"container/list"
-	"time"
-)
+import "container/list"
 
 type LRUCache struct {
 	baseCache
@@ -16,6 +13,8 @@ func newLRUCache(cb *CacheBuilder) *LRUCache {
 	buildCache(&c.baseCache, cb)
 	c.init()
 	c.group.cache = c
+	c.store = c
+	c.startJanitor(cb.cleanupInterval)
 	return c
 }
 
@@ -24,233 +23,107 @@ func (c *LRUCache) init() {
 	c.items = make(map[interface{}]*list.Element)
 }
 
-func (c *LRUCache) Set(key, value interface{}) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	_, err := c.set(key, value)
-	return err
-}
-
-func (c *LRUCache) set(key, value interface{}) (interface{}, error) {
+func (c *LRUCache) set(key, value interface{}) (*cacheItem, error) {
+	w, err := c.weigh(key, value)
+	if err != nil {
+		return nil, err
+	}
 	var item *lruItem
 	if it, ok := c.items[key]; ok {
 		c.evictList.MoveToFront(it)
 		item = it.Value.(*lruItem)
-		item.value = value
+		c.replaceValue(&item.cacheItem, value)
 	} else {
 		if c.evictList.Len() >= c.size {
 			c.evict(1)
 		}
 		item = &lruItem{
-			clock: c.clock,
-			key:   key,
-			value: value,
+			cacheItem: c.newItem(key, value),
 		}
 		c.items[key] = c.evictList.PushFront(item)
 	}
-	if c.expiration != nil {
-		t := c.clock.Now().Add(*c.expiration)
-		item.expiration = &t
-	}
-	if c.addedFunc != nil {
-		c.addedFunc(key, value)
-	}
-	return item, nil
+	c.written(&item.cacheItem)
+	c.setWeight(&item.cacheItem, w)
+	c.evictOverweight()
+	c.added(key, value)
+	return &item.cacheItem, nil
 }
 
-// 直接去掉链表最末端
+// 先删除已经过期的元素 不够时再去掉链表最末端
 func (c *LRUCache) evict(count int) {
+	count -= c.removeExpired()
 	for i := 0; i < count; i++ {
 		tail := c.evictList.Back()
 		if tail == nil {
 			return
 		} else {
-			c.removeElement(tail)
+			c.removeElement(tail, RemovalSize)
 		}
 	}
 }
 
-func (c *LRUCache) SetWithExpire(key, value interface{}, expiration time.Duration) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	item, err := c.set(key, value)
-	if err != nil {
-		return err
-	}
-	t := c.clock.Now().Add(expiration)
-	item.(*lruItem).expiration = &t
-	return nil
-}
-
-func (c *LRUCache) Get(key interface{}) (interface{}, error) {
-	v, err := c.get(key, false)
-	if err == KeyNotFoundError {
-		return c.getWithLoader(key, true)
-	}
-	return v, err
-}
-
-func (c *LRUCache) GetALL(checkExpired bool) map[interface{}]interface{} {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	items := make(map[interface{}]interface{}, len(c.items))
-	now := time.Now()
-	for k, item := range c.items {
-		if !checkExpired || c.has(k, &now) {
-			items[k] = item.Value.(*lruItem).value
-		}
+// evictOverweight 从链表末端淘汰直到总重量不超过 maxWeight
+// 刚写入的元素在链表最前端 只有它自己时不会被淘汰
+func (c *LRUCache) evictOverweight() {
+	if !c.overweight() {
+		return
 	}
-	return items
-}
-
-func (c *LRUCache) GetIfPresent(key interface{}) (interface{}, error) {
-	v, err := c.get(key, false)
-	if err == KeyNotFoundError {
-		return c.getWithLoader(key, false)
-	}
-	return v, err
-}
-
-func (c *LRUCache) get(key interface{}, onLoad bool) (interface{}, error) {
-	v, err := c.getValue(key, onLoad)
-	if err != nil {
-		return nil, err
-	}
-	return v, nil
-}
-
-func (c *LRUCache) getWithLoader(key interface{}, isWait bool) (interface{}, error) {
-	if c.loaderExpireFunc == nil {
-		return nil, KeyNotFoundError
-	}
-	value, _, err := c.load(key, func(v interface{}, expiration *time.Duration, e error) (interface{}, error) {
-		if e != nil {
-			return nil, e
-		}
-		c.mu.Lock()
-		defer c.mu.Unlock()
-		item, err := c.set(key, v)
-		if err != nil {
-			return nil, err
-		}
-		if expiration != nil {
-			t := c.clock.Now().Add(*expiration)
-			item.(*lruItem).expiration = &t
-		}
-		return v, nil
-	}, isWait)
-	if err != nil {
-		return nil, err
+	c.removeExpired()
+	for c.overweight() && c.evictList.Len() > 1 {
+		c.removeElement(c.evictList.Back(), RemovalSize)
 	}
-	return value, nil
 }
 
-func (c *LRUCache) getValue(key interface{}, onLoad bool) (interface{}, error) {
-	c.mu.Lock()
+// lookup 查找未过期的元素 调用时需持有 mu
+func (c *LRUCache) lookup(key interface{}) (*cacheItem, bool) {
 	item, ok := c.items[key]
 	if ok {
 		it := item.Value.(*lruItem)
 		if !it.IsExpired(nil) {
 			c.evictList.MoveToFront(item)
-			v := it.value
-			c.mu.Unlock()
-			if !onLoad {
-				c.stats.IncrHitCount()
-			}
-			return v, nil
+			return &it.cacheItem, true
 		}
 		// 如果缓存过期了 删除这个节点
-		c.removeElement(item)
+		c.keepStale(&it.cacheItem)
+		c.removeElement(item, RemovalExpired)
 	}
-	c.mu.Unlock()
-	if !onLoad {
-		c.stats.IncrMissCount()
-	}
-	return nil, KeyNotFoundError
+	return nil, false
 }
 
-func (c *LRUCache) removeElement(e *list.Element) {
-	c.evictList.Remove(e)
-	entry := e.Value.(*lruItem)
-	delete(c.items, entry.key)
-	if c.evictedFunc != nil {
-		c.evictedFunc(entry.key, entry.value)
+// peek 返回 key 对应的元素 不会删除过期的元素 调用时需持有 mu
+func (c *LRUCache) peek(key interface{}) (*cacheItem, bool) {
+	item, ok := c.items[key]
+	if !ok {
+		return nil, false
 	}
+	return &item.Value.(*lruItem).cacheItem, true
 }
 
-func (c *LRUCache) Remove(key interface{}) bool {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	return c.remove(key)
-}
-
-func (c *LRUCache) remove(key interface{}) bool {
-	if ent, ok := c.items[key]; ok {
-		c.removeElement(ent)
-		return true
+func (c *LRUCache) each(fn func(item *cacheItem)) {
+	for _, e := range c.items {
+		fn(&e.Value.(*lruItem).cacheItem)
 	}
-	return false
 }
 
-func (c *LRUCache) Keys(checkExpired bool) []interface{} {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	keys := make([]interface{}, 0, len(c.items))
-	now := time.Now()
-	for key := range c.items {
-		if !checkExpired || c.has(key, &now) {
-			keys = append(keys, key)
-		}
-	}
-	return keys
+func (c *LRUCache) length() int {
+	return len(c.items)
 }
 
-func (c *LRUCache) Len(checkExpired bool) int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	if !checkExpired {
-		return len(c.items)
-	}
-	length := 0
-	now := time.Now()
-	for key := range c.items {
-		if c.has(key, &now) {
-			length++
-		}
-	}
-	return length
-}
-
-func (c *LRUCache) Has(key interface{}) bool {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	now := time.Now()
-	return c.has(key, &now)
+func (c *LRUCache) removeElement(e *list.Element, cause RemovalCause) {
+	c.evictList.Remove(e)
+	entry := e.Value.(*lruItem)
+	delete(c.items, entry.key)
+	c.removed(&entry.cacheItem, cause)
 }
 
-func (c *LRUCache) has(key interface{}, now *time.Time) bool {
-	item, ok := c.items[key]
-	if !ok {
-		return false
+func (c *LRUCache) remove(key interface{}, cause RemovalCause) bool {
+	if ent, ok := c.items[key]; ok {
+		c.removeElement(ent, cause)
+		return true
 	}
-	return !item.Value.(*lruItem).IsExpired(now)
+	return false
 }
 
 type lruItem struct {
-	clock      Clock
-	key        interface{}
-	value      interface{}
-	expiration *time.Time
-}
-
-func (it *lruItem) IsExpired(now *time.Time) bool {
-	if it.expiration == nil {
-		return false
-	}
-	if now == nil {
-		t := it.clock.Now()
-		now = &t
-	}
-	return it.expiration.Before(*now)
+	cacheItem
 }
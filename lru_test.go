@@ -47,6 +47,66 @@ func TestLRUGetIFPresent(t *testing.T) {
 	testGetIFPresent(t, TypeLru)
 }
 
+func TestLRUGetCtx(t *testing.T) {
+	testGetCtx(t, TypeLru)
+}
+
+func TestLRUGetMany(t *testing.T) {
+	testGetMany(t, TypeLru)
+}
+
+func TestLRUCompute(t *testing.T) {
+	testCompute(t, TypeLru)
+}
+
+func TestLRURefreshAfterWrite(t *testing.T) {
+	testRefreshAfterWrite(t, TypeLru)
+}
+
+func TestLRUStaleIfError(t *testing.T) {
+	testStaleIfError(t, TypeLru)
+}
+
+func TestLRUNegativeTTL(t *testing.T) {
+	testNegativeTTL(t, TypeLru)
+}
+
+func TestLRUWeigher(t *testing.T) {
+	testWeigher(t, TypeLru)
+}
+
+func TestLRUExpireAfterAccess(t *testing.T) {
+	testExpireAfterAccess(t, TypeLru)
+}
+
+func TestLRUExpiry(t *testing.T) {
+	testExpiry(t, TypeLru)
+}
+
+func TestLRUCleanupInterval(t *testing.T) {
+	testCleanupInterval(t, TypeLru)
+}
+
+func TestLRUEvictExpiredFirst(t *testing.T) {
+	testEvictExpiredFirst(t, TypeLru)
+}
+
+func TestLRURemovalListener(t *testing.T) {
+	testRemovalListener(t, TypeLru)
+}
+
+func TestLRUAsyncListeners(t *testing.T) {
+	testAsyncListeners(t, TypeLru)
+}
+
+func TestLRUPurge(t *testing.T) {
+	testPurge(t, TypeLru)
+}
+
+func TestLRUStats(t *testing.T) {
+	testStats(t, TypeLru)
+}
+
 func TestLRUHas(t *testing.T) {
 	gc := buildTestLoadingCacheWithExpiration(t, TypeLru, 2, 10*time.Millisecond)
 
@@ -0,0 +1,100 @@
+package hyliocache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+/*
+negative 模块在开启 NegativeTTL 之后缓存加载器返回的错误
+加载器可以返回 KeyNotFoundError 表示 key 不存在
+在 TTL 之内 Get 会直接返回缓存的错误而不会再次调用加载器
+这些错误保存在 baseCache.negatives 中 不占用缓存的容量
+*/
+
+type negativeEntry struct {
+	err        error
+	expiration time.Time
+}
+
+// keepNegative 缓存 key 加载失败的错误 调用时需持有 mu
+func (c *baseCache) keepNegative(key interface{}, err error) {
+	if c.negativeTTL == nil || isContextError(err) {
+		return
+	}
+	if c.negatives == nil {
+		c.negatives = make(map[interface{}]*negativeEntry)
+	}
+	now := c.clock.Now()
+	if c.size > 0 && len(c.negatives) >= c.size {
+		c.pruneNegatives(now)
+	}
+	c.negatives[key] = &negativeEntry{
+		err:        err,
+		expiration: now.Add(*c.negativeTTL),
+	}
+}
+
+// pruneNegatives 删除已经过期的错误
+// 仍然放不下时随机删除一个
+func (c *baseCache) pruneNegatives(now time.Time) {
+	for key, entry := range c.negatives {
+		if entry.expiration.Before(now) {
+			delete(c.negatives, key)
+		}
+	}
+	if len(c.negatives) < c.size {
+		return
+	}
+	for key := range c.negatives {
+		delete(c.negatives, key)
+		return
+	}
+}
+
+// negativeErr 返回 key 缓存的错误 调用时需持有 mu
+func (c *baseCache) negativeErr(key interface{}) (error, bool) {
+	entry, ok := c.negatives[key]
+	if !ok {
+		return nil, false
+	}
+	if entry.expiration.Before(c.clock.Now()) {
+		delete(c.negatives, key)
+		return nil, false
+	}
+	return entry.err, true
+}
+
+// cachedErr 与 negativeErr 相同 但会自己加锁并统计命中次数
+func (c *baseCache) cachedErr(key interface{}) (error, bool) {
+	if c.negativeTTL == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	err, ok := c.negativeErr(key)
+	c.unlock()
+	if ok {
+		c.stats.IncrNegativeHitCount()
+	}
+	return err, ok
+}
+
+// filterNegatives 去掉已经缓存了错误的 key
+func (c *baseCache) filterNegatives(keys []interface{}) []interface{} {
+	c.mu.Lock()
+	defer c.unlock()
+	filtered := keys[:0:0]
+	for _, key := range keys {
+		if _, ok := c.negativeErr(key); ok {
+			c.stats.IncrNegativeHitCount()
+			continue
+		}
+		filtered = append(filtered, key)
+	}
+	return filtered
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,203 @@
+package hyliocache
+
+import (
+	"fmt"
+	"sync"
+)
+
+/*
+policy 模块把元素保存在 map 中 淘汰的顺序交给 EvictionPolicy 决定
+新的淘汰策略只需要实现 EvictionPolicy 过期 加载 singleflight 统计和回调都由 policyCache 负责
+通过 RegisterPolicy 注册的淘汰策略可以用 CacheBuilder.EvictType 选择
+*/
+
+// EvictionPolicy 只记录 key 的顺序 所有方法都在持有缓存的锁时调用 因此不需要自己加锁
+type EvictionPolicy interface {
+	// OnInsert 新的 key 被放入缓存
+	OnInsert(key interface{})
+	// OnAccess 已经存在的 key 被读取或者被重新写入
+	OnAccess(key interface{})
+	// OnRemove key 被删除 包括被淘汰 过期和被显式删除
+	OnRemove(key interface{})
+	// Victim 返回下一个应该被淘汰的 key 这个 key 必须在缓存中
+	// 缓存随后会删除它并调用 OnRemove 没有可以淘汰的 key 时返回 false
+	Victim() (interface{}, bool)
+}
+
+// CostAwarePolicy 是需要知道元素的代价和重量的淘汰策略
+// 元素写入之后 以及代价或者重量改变之后会调用 OnCost
+type CostAwarePolicy interface {
+	EvictionPolicy
+	OnCost(key interface{}, cost float64, weight int64)
+}
+
+// PolicyFactory 创建一个容量为 size 的淘汰策略 每个缓存 (以及每个分片) 都会调用一次
+type PolicyFactory func(size int) EvictionPolicy
+
+var (
+	policiesMu sync.RWMutex
+	policies   = make(map[string]PolicyFactory)
+)
+
+// RegisterPolicy 注册名为 name 的淘汰策略 之后可以通过 EvictType(name) 使用
+// name 与内置的类型或者已经注册的策略重复时会 panic
+func RegisterPolicy(name string, factory PolicyFactory) {
+	if factory == nil {
+		panic("hyliocache: RegisterPolicy factory is nil")
+	}
+	policiesMu.Lock()
+	defer policiesMu.Unlock()
+	if _, dup := policies[name]; dup || isBuiltinType(name) {
+		panic(fmt.Sprintf("hyliocache: RegisterPolicy called twice for %q", name))
+	}
+	policies[name] = factory
+}
+
+func lookupPolicy(name string) (PolicyFactory, bool) {
+	policiesMu.RLock()
+	defer policiesMu.RUnlock()
+	factory, ok := policies[name]
+	return factory, ok
+}
+
+func isBuiltinType(name string) bool {
+	switch name {
+	case TypeSimple, TypeLru, TypeLfu, TypeArc, TypeWTinyLFU, TypeTwoQueue,
+		TypeS3FIFO, TypeClock, TypeClockPro, TypeLIRS, TypeGDSF:
+		return true
+	}
+	return false
+}
+
+// sharedAccessPolicy 的 OnAccess 只做原子操作 可以在只持有读锁时调用
+type sharedAccessPolicy interface {
+	EvictionPolicy
+	sharedAccess()
+}
+
+type policyCache struct {
+	baseCache
+	items   map[interface{}]*cacheItem
+	policy  EvictionPolicy
+	factory PolicyFactory
+}
+
+func newPolicyCache(cb *CacheBuilder, factory PolicyFactory) *policyCache {
+	c := &policyCache{factory: factory}
+	buildCache(&c.baseCache, cb)
+	c.init()
+	c.group.cache = c
+	c.store = c
+	c.startJanitor(cb.cleanupInterval)
+	return c
+}
+
+func (c *policyCache) init() {
+	c.items = make(map[interface{}]*cacheItem, c.size)
+	c.policy = c.factory(c.size)
+}
+
+func (c *policyCache) set(key, value interface{}) (*cacheItem, error) {
+	w, err := c.weigh(key, value)
+	if err != nil {
+		return nil, err
+	}
+	item, ok := c.items[key]
+	if ok {
+		c.replaceValue(item, value)
+		c.policy.OnAccess(key)
+	} else {
+		it := c.newItem(key, value)
+		item = &it
+		c.items[key] = item
+		c.policy.OnInsert(key)
+	}
+	c.written(item)
+	c.setWeight(item, w)
+	c.costChanged(item)
+	c.evict()
+	if c.items[key] != item {
+		// 淘汰策略淘汰了刚写入的 key 此时不再调用 AddedFunc
+		return nil, nil
+	}
+	c.added(key, value)
+	return item, nil
+}
+
+// evict 淘汰元素直到元素个数和总重量都不超过限制
+// 只剩一个元素时不会因为重量被淘汰
+func (c *policyCache) evict() {
+	if len(c.items) > c.size || c.overweight() {
+		c.removeExpired()
+	}
+	for len(c.items) > c.size || (c.overweight() && len(c.items) > 1) {
+		key, ok := c.policy.Victim()
+		if !ok || !c.remove(key, RemovalSize) {
+			return
+		}
+	}
+}
+
+// lookup 查找未过期的元素 调用时需持有 mu
+func (c *policyCache) lookup(key interface{}) (*cacheItem, bool) {
+	item, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if item.IsExpired(nil) {
+		c.keepStale(item)
+		c.remove(key, RemovalExpired)
+		return nil, false
+	}
+	c.policy.OnAccess(key)
+	return item, true
+}
+
+// lookupShared 在只持有读锁时查找未过期的元素
+// 过期的元素需要删除 按照访问时间过期的元素需要更新过期时间 都交给 lookup 处理
+func (c *policyCache) lookupShared(key interface{}) (*cacheItem, bool) {
+	policy, ok := c.policy.(sharedAccessPolicy)
+	if !ok {
+		return nil, false
+	}
+	item, ok := c.items[key]
+	if !ok || item.idle != nil || c.expiry != nil || item.IsExpired(nil) {
+		return nil, false
+	}
+	policy.OnAccess(key)
+	return item, true
+}
+
+// peek 返回 key 对应的元素 不会删除过期的元素 调用时需持有 mu
+func (c *policyCache) peek(key interface{}) (*cacheItem, bool) {
+	item, ok := c.items[key]
+	return item, ok
+}
+
+func (c *policyCache) remove(key interface{}, cause RemovalCause) bool {
+	item, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	delete(c.items, key)
+	c.policy.OnRemove(key)
+	c.removed(item, cause)
+	return true
+}
+
+// costChanged 把元素的代价和重量告诉 CostAwarePolicy
+func (c *policyCache) costChanged(item *cacheItem) {
+	if policy, ok := c.policy.(CostAwarePolicy); ok {
+		policy.OnCost(item.key, item.cost, item.weight)
+	}
+}
+
+func (c *policyCache) each(fn func(item *cacheItem)) {
+	for _, item := range c.items {
+		fn(item)
+	}
+}
+
+func (c *policyCache) length() int {
+	return len(c.items)
+}
@@ -0,0 +1,153 @@
+package hyliocache
+
+import (
+	"container/list"
+	"testing"
+	"time"
+)
+
+// fifoPolicy 按照写入的顺序淘汰
+type fifoPolicy struct {
+	l    *list.List
+	keys map[interface{}]*list.Element
+}
+
+func newFIFOPolicy(size int) EvictionPolicy {
+	return &fifoPolicy{
+		l:    list.New(),
+		keys: make(map[interface{}]*list.Element, size),
+	}
+}
+
+func (p *fifoPolicy) OnInsert(key interface{}) {
+	p.keys[key] = p.l.PushFront(key)
+}
+
+func (p *fifoPolicy) OnAccess(key interface{}) {}
+
+func (p *fifoPolicy) OnRemove(key interface{}) {
+	if e, ok := p.keys[key]; ok {
+		p.l.Remove(e)
+		delete(p.keys, key)
+	}
+}
+
+func (p *fifoPolicy) Victim() (interface{}, bool) {
+	if e := p.l.Back(); e != nil {
+		return e.Value, true
+	}
+	return nil, false
+}
+
+// lifoPolicy 总是淘汰最后写入的 key 用来模拟拒绝新元素的淘汰策略
+type lifoPolicy struct {
+	*fifoPolicy
+}
+
+func newLIFOPolicy(size int) EvictionPolicy {
+	return lifoPolicy{newFIFOPolicy(size).(*fifoPolicy)}
+}
+
+func (p lifoPolicy) Victim() (interface{}, bool) {
+	if e := p.l.Front(); e != nil {
+		return e.Value, true
+	}
+	return nil, false
+}
+
+const (
+	typeFIFO = "test-fifo"
+	typeLIFO = "test-lifo"
+)
+
+func init() {
+	RegisterPolicy(typeFIFO, newFIFOPolicy)
+	RegisterPolicy(typeLIFO, newLIFOPolicy)
+}
+
+func TestRegisterPolicy(t *testing.T) {
+	size := 10
+	var evicted []interface{}
+	gc := New(size).
+		EvictType(typeFIFO).
+		EvictedFunc(func(key, value interface{}) {
+			evicted = append(evicted, key)
+		}).
+		Build()
+	setItemsByRange(t, gc, 0, size)
+	gc.Get(0)
+	setItemsByRange(t, gc, size, size+2)
+	if len(evicted) != 2 || evicted[0] != 0 || evicted[1] != 1 {
+		t.Fatalf("keys should be evicted in insertion order, evicted %v", evicted)
+	}
+	if l := gc.Len(false); l != size {
+		t.Fatalf("%v != %v", l, size)
+	}
+
+	for _, name := range []string{typeFIFO, TypeLru} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering %q again should panic", name)
+				}
+			}()
+			RegisterPolicy(name, newFIFOPolicy)
+		}()
+	}
+}
+
+func TestRegisteredPolicyGetIFPresent(t *testing.T) {
+	testGetIFPresent(t, typeFIFO)
+}
+
+func TestRegisteredPolicyCompute(t *testing.T) {
+	testCompute(t, typeFIFO)
+}
+
+func TestRegisteredPolicyWeigher(t *testing.T) {
+	testWeigher(t, typeFIFO)
+}
+
+func TestRegisteredPolicyShards(t *testing.T) {
+	gc := New(100).EvictType(typeFIFO).Shards(4).Build()
+	setItemsByRange(t, gc, 0, 200)
+	if l := gc.Len(false); l > 100 {
+		t.Fatalf("%v > 100", l)
+	}
+}
+
+func TestRegisteredPolicyRejectNewKey(t *testing.T) {
+	var added []interface{}
+	gc := New(2).
+		EvictType(typeLIFO).
+		LoaderExpireFunc(func(key interface{}) (interface{}, *time.Duration, error) {
+			d := time.Minute
+			return key, &d, nil
+		}).
+		AddedFunc(func(key, value interface{}) {
+			added = append(added, key)
+		}).
+		Build()
+	setItemsByRange(t, gc, 0, 2)
+	if err := gc.SetWithExpire(2, 2, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := gc.SetWithExpireAfterAccess(3, 3, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := gc.SetWithCost(4, 4, 1); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := gc.Get(5); err != nil || v != 5 {
+		t.Fatalf("loader result should be returned, got %v %v", v, err)
+	}
+	if gc.Has(2) || gc.Has(3) || gc.Has(4) || gc.Has(5) {
+		t.Fatal("rejected keys should not be in the cache")
+	}
+	if len(added) != 2 {
+		t.Fatalf("AddedFunc should not be called for rejected keys, called for %v", added)
+	}
+	if n := gc.(*policyCache).timers.Len(); n != 0 {
+		t.Fatalf("rejected keys should not be in the expiry queue, %d left", n)
+	}
+}
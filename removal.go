@@ -0,0 +1,74 @@
+package hyliocache
+
+/*
+removal 模块记录元素被删除的原因 并通知 RemovalListener
+容量不足时所有的淘汰策略都会先删除已经过期的元素 再按照各自的规则淘汰未过期的元素
+*/
+
+// RemovalCause 元素被删除的原因
+type RemovalCause int
+
+const (
+	// RemovalExplicit 通过 Remove 或者 Compute 删除
+	RemovalExplicit RemovalCause = iota
+	// RemovalExpired 元素已经过期
+	RemovalExpired
+	// RemovalSize 超过容量或者最大重量被淘汰
+	RemovalSize
+	// RemovalReplaced 值被新的值覆盖 key 仍然在缓存中
+	RemovalReplaced
+	// RemovalCleared 缓存被清空
+	RemovalCleared
+
+	removalCauses
+)
+
+// RemovalListener 在元素被删除或者值被覆盖时调用 value 是被删除或者被覆盖的值
+type RemovalListener func(key, value interface{}, cause RemovalCause)
+
+func (c RemovalCause) String() string {
+	switch c {
+	case RemovalExplicit:
+		return "explicit"
+	case RemovalExpired:
+		return "expired"
+	case RemovalSize:
+		return "size"
+	case RemovalReplaced:
+		return "replaced"
+	case RemovalCleared:
+		return "cleared"
+	default:
+		return "unknown"
+	}
+}
+
+// removeExpired 删除所有已经过期的元素 返回删除的个数 调用时需持有 mu
+func (c *baseCache) removeExpired() int {
+	n := 0
+	now := c.clock.Now()
+	for item := c.timers.peek(); item != nil && item.IsExpired(&now); item = c.timers.peek() {
+		c.keepStale(item)
+		if c.store.remove(item.key, RemovalExpired) {
+			n++
+		} else {
+			c.timers.remove(item)
+		}
+	}
+	return n
+}
+
+// replaceValue 用 value 覆盖已经存在的元素的值 调用时需持有 mu
+// 被覆盖的值已经过期时原因是 RemovalExpired
+func (c *baseCache) replaceValue(item *cacheItem, value interface{}) {
+	cause := RemovalReplaced
+	if item.IsExpired(nil) {
+		cause = RemovalExpired
+	}
+	old := item.value
+	item.value = value
+	c.stats.IncrRemovalCount(cause)
+	if c.removalListener != nil {
+		c.notify(event{kind: eventRemoved, key: item.key, value: old, cause: cause})
+	}
+}
@@ -0,0 +1,118 @@
+package hyliocache
+
+import (
+	"container/list"
+	"sync/atomic"
+)
+
+/*
+s3fifo 模块实现 S3-FIFO 淘汰策略
+新的元素进入小 FIFO 队列 在小队列中被访问过的元素进入主队列 否则记录在幽灵队列中
+幽灵队列中的 key 再次被写入时直接进入主队列
+主队列中被访问过的元素会被重新放回队首 同时访问次数减一
+命中时只需要原子地增加访问次数 不需要移动链表中的元素
+*/
+
+const s3MaxFreq = 3
+
+type s3Node struct {
+	key   interface{}
+	freq  int32 // 被访问的次数 最大为 s3MaxFreq
+	main  bool  // 是否在主队列中
+	queue *list.Element
+}
+
+type s3FIFO struct {
+	nodes    map[interface{}]*s3Node
+	small    *list.List
+	main     *list.List
+	ghost    *arcList
+	smallCap int
+	ghostCap int
+}
+
+// newS3FIFO 小队列占容量的 10% 幽灵队列记录的 key 的数量与主队列的容量相同
+func newS3FIFO(size int) *s3FIFO {
+	smallCap := max(1, size/10)
+	return &s3FIFO{
+		nodes:    make(map[interface{}]*s3Node, size),
+		small:    list.New(),
+		main:     list.New(),
+		ghost:    newArcList(),
+		smallCap: smallCap,
+		ghostCap: max(1, size-smallCap),
+	}
+}
+
+func (p *s3FIFO) OnInsert(key interface{}) {
+	node := &s3Node{key: key}
+	if ele := p.ghost.Get(key); ele != nil {
+		p.ghost.Remove(key, ele)
+		node.main = true
+		node.queue = p.main.PushFront(node)
+	} else {
+		node.queue = p.small.PushFront(node)
+	}
+	p.nodes[key] = node
+}
+
+// OnAccess 可以在只持有读锁时调用
+func (p *s3FIFO) OnAccess(key interface{}) {
+	node, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	for {
+		freq := atomic.LoadInt32(&node.freq)
+		if freq >= s3MaxFreq || atomic.CompareAndSwapInt32(&node.freq, freq, freq+1) {
+			return
+		}
+	}
+}
+
+func (p *s3FIFO) sharedAccess() {}
+
+func (p *s3FIFO) OnRemove(key interface{}) {
+	node, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	if node.main {
+		p.main.Remove(node.queue)
+	} else {
+		p.small.Remove(node.queue)
+	}
+	delete(p.nodes, key)
+}
+
+func (p *s3FIFO) Victim() (interface{}, bool) {
+	for {
+		if p.small.Len() > p.smallCap || (p.main.Len() == 0 && p.small.Len() > 0) {
+			node := p.small.Back().Value.(*s3Node)
+			if atomic.LoadInt32(&node.freq) > 0 {
+				// 在小队列中被访问过 移动到主队列
+				p.small.Remove(node.queue)
+				atomic.StoreInt32(&node.freq, 0)
+				node.main = true
+				node.queue = p.main.PushFront(node)
+				continue
+			}
+			p.ghost.PushFront(node.key)
+			for p.ghost.Len() > p.ghostCap {
+				p.ghost.RemoveTail()
+			}
+			return node.key, true
+		}
+		e := p.main.Back()
+		if e == nil {
+			return nil, false
+		}
+		node := e.Value.(*s3Node)
+		if atomic.LoadInt32(&node.freq) > 0 {
+			atomic.AddInt32(&node.freq, -1)
+			p.main.MoveToFront(e)
+			continue
+		}
+		return node.key, true
+	}
+}
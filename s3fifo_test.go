@@ -0,0 +1,192 @@
+package hyliocache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestS3FIFOGet(t *testing.T) {
+	size := 1000
+	gc := buildTestCache(t, TypeS3FIFO, size)
+	testSetCache(t, gc, size)
+	testGetCache(t, gc, size)
+}
+
+func TestLoadingS3FIFOGet(t *testing.T) {
+	size := 1000
+	gc := buildTestLoadingCache(t, TypeS3FIFO, size, loader)
+	testGetCache(t, gc, size)
+}
+
+func TestS3FIFOLength(t *testing.T) {
+	gc := buildTestLoadingCache(t, TypeS3FIFO, 1000, loader)
+	gc.Get("test1")
+	gc.Get("test2")
+	length := gc.Len(true)
+	expectedLength := 2
+	if length != expectedLength {
+		t.Errorf("Expected length is %v, not %v", length, expectedLength)
+	}
+}
+
+func TestS3FIFOEvictItem(t *testing.T) {
+	cacheSize := 10
+	numbers := 11
+	gc := buildTestLoadingCache(t, TypeS3FIFO, cacheSize, loader)
+
+	for i := 0; i < numbers; i++ {
+		_, err := gc.Get(fmt.Sprintf("Key-%d", i))
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func TestS3FIFOGetIFPresent(t *testing.T) {
+	testGetIFPresent(t, TypeS3FIFO)
+}
+
+func TestS3FIFOGetCtx(t *testing.T) {
+	testGetCtx(t, TypeS3FIFO)
+}
+
+func TestS3FIFOGetMany(t *testing.T) {
+	testGetMany(t, TypeS3FIFO)
+}
+
+func TestS3FIFOCompute(t *testing.T) {
+	testCompute(t, TypeS3FIFO)
+}
+
+func TestS3FIFORefreshAfterWrite(t *testing.T) {
+	testRefreshAfterWrite(t, TypeS3FIFO)
+}
+
+func TestS3FIFOStaleIfError(t *testing.T) {
+	testStaleIfError(t, TypeS3FIFO)
+}
+
+func TestS3FIFONegativeTTL(t *testing.T) {
+	testNegativeTTL(t, TypeS3FIFO)
+}
+
+func TestS3FIFOWeigher(t *testing.T) {
+	testWeigher(t, TypeS3FIFO)
+}
+
+func TestS3FIFOExpireAfterAccess(t *testing.T) {
+	testExpireAfterAccess(t, TypeS3FIFO)
+}
+
+func TestS3FIFOExpiry(t *testing.T) {
+	testExpiry(t, TypeS3FIFO)
+}
+
+func TestS3FIFOCleanupInterval(t *testing.T) {
+	testCleanupInterval(t, TypeS3FIFO)
+}
+
+func TestS3FIFOEvictExpiredFirst(t *testing.T) {
+	testEvictExpiredFirst(t, TypeS3FIFO)
+}
+
+func TestS3FIFORemovalListener(t *testing.T) {
+	testRemovalListener(t, TypeS3FIFO)
+}
+
+func TestS3FIFOAsyncListeners(t *testing.T) {
+	testAsyncListeners(t, TypeS3FIFO)
+}
+
+func TestS3FIFOPurge(t *testing.T) {
+	testPurge(t, TypeS3FIFO)
+}
+
+func TestS3FIFOStats(t *testing.T) {
+	testStats(t, TypeS3FIFO)
+}
+
+func TestS3FIFOHas(t *testing.T) {
+	gc := buildTestLoadingCacheWithExpiration(t, TypeS3FIFO, 2, 10*time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			gc.Get("test1")
+			gc.Get("test2")
+
+			if gc.Has("test0") {
+				t.Fatal("should not have test0")
+			}
+			if !gc.Has("test1") {
+				t.Fatal("should have test1")
+			}
+			if !gc.Has("test2") {
+				t.Fatal("should have test2")
+			}
+
+			time.Sleep(20 * time.Millisecond)
+
+			if gc.Has("test0") {
+				t.Fatal("should not have test0")
+			}
+			if gc.Has("test1") {
+				t.Fatal("should not have test1")
+			}
+			if gc.Has("test2") {
+				t.Fatal("should not have test2")
+			}
+		})
+	}
+}
+
+func TestS3FIFOEvictOrder(t *testing.T) {
+	size := 10
+	gc := buildTestCache(t, TypeS3FIFO, size)
+	setItemsByRange(t, gc, 0, size)
+	// 被访问过的元素会进入主队列 其他元素依次被淘汰
+	gc.Get(0)
+	gc.Get(1)
+	setItemsByRange(t, gc, 100, 110)
+	for i := 0; i < size; i++ {
+		if has := gc.Has(i); has != (i < 2) {
+			t.Fatalf("Has(%v) should be %v", i, i < 2)
+		}
+	}
+
+	// 幽灵队列中的 key 再次被写入时直接进入主队列
+	gc.Set(5, 5)
+	setItemsByRange(t, gc, 200, 220)
+	if !gc.Has(5) {
+		t.Fatal("should have 5")
+	}
+}
+
+func TestS3FIFOConcurrentGet(t *testing.T) {
+	size := 100
+	gc := buildTestLoadingCache(t, TypeS3FIFO, size, loader)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := fmt.Sprintf("Key-%d", (i*j)%(2*size))
+				v, err := gc.Get(key)
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+					return
+				}
+				if expected, _ := loader(key); v != expected {
+					t.Errorf("Expected value is %v, not %v", expected, v)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if l := gc.Len(false); l > size {
+		t.Fatalf("%v > %v", l, size)
+	}
+}
@@ -0,0 +1,383 @@
+package hyliocache
+
+import (
+	"context"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+/*
+sharded 模块把 key 按照哈希值分散到多个相互独立的缓存中
+每个分片都有自己的锁 以此降低多核下的锁竞争
+所有分片共享一个 dispatcher 和一个后台清理的 goroutine
+GetMany 中所有分片未命中的 key 合并到一次 batchLoaderFunc 调用中
+*/
+
+// KeyHasher 返回 key 的哈希值 相等的 key 必须返回相同的值
+type KeyHasher func(key interface{}) uint64
+
+// shardCache 是 shardedCache 需要的分片的内部方法 所有的缓存类型都通过 baseCache 实现
+type shardCache interface {
+	Cache
+	lookupMany(keys []interface{}) (map[interface{}]interface{}, []interface{})
+	loadMany(ctx context.Context, items map[interface{}]interface{}, misses []interface{}) (map[interface{}]interface{}, error)
+	callBatchLoader(keys []interface{}) (map[interface{}]interface{}, time.Duration, error)
+	cleanup()
+}
+
+type shardedCache struct {
+	shards  []shardCache
+	hasher  KeyHasher
+	janitor *janitor
+}
+
+func newShardedCache(cb *CacheBuilder) *shardedCache {
+	// 每个分片至少有一个元素的容量和一个单位的重量
+	n := cb.shards
+	if cb.size > 0 {
+		n = min(n, cb.size)
+	}
+	if cb.maxWeight > 0 && int64(n) > cb.maxWeight {
+		n = int(cb.maxWeight)
+	}
+	c := &shardedCache{
+		shards: make([]shardCache, n),
+		hasher: cb.keyHasher,
+	}
+	if c.hasher == nil {
+		seed := maphash.MakeSeed()
+		c.hasher = func(key interface{}) uint64 {
+			return maphash.Comparable(seed, key)
+		}
+	}
+	var d *dispatcher
+	if cb.asyncQueueSize > 0 {
+		d = newDispatcher(cb.asyncQueueSize, cb.asyncFullPolicy)
+	}
+	for i := range c.shards {
+		// 容量和最大重量平均分配到每个分片 余数分给前面的分片 总和与设置的相同
+		shard := *cb
+		shard.shards = 0
+		shard.size = cb.size / n
+		if i < cb.size%n {
+			shard.size++
+		}
+		shard.maxWeight = cb.maxWeight / int64(n)
+		if int64(i) < cb.maxWeight%int64(n) {
+			shard.maxWeight++
+		}
+		// 单个元素的重量仍然按照整个缓存的最大重量检查
+		shard.maxItemWeight = cb.maxWeight
+		shard.dispatcher = d
+		shard.cleanupInterval = nil
+		c.shards[i] = shard.build().(shardCache)
+	}
+	c.janitor = newJanitor(cb.clock, cb.cleanupInterval, c.cleanup)
+	return c
+}
+
+func (c *shardedCache) cleanup() {
+	for _, shard := range c.shards {
+		shard.cleanup()
+	}
+}
+
+// Purge 依次清空每个分片 不同分片之间不是原子的
+func (c *shardedCache) Purge() {
+	for _, shard := range c.shards {
+		shard.Purge()
+	}
+}
+
+func (c *shardedCache) Close() {
+	c.janitor.close()
+	for _, shard := range c.shards {
+		shard.Close()
+	}
+}
+
+func (c *shardedCache) shard(key interface{}) shardCache {
+	return c.shards[c.hasher(key)%uint64(len(c.shards))]
+}
+
+func (c *shardedCache) Set(key, value interface{}) error {
+	return c.shard(key).Set(key, value)
+}
+
+func (c *shardedCache) SetWithExpire(key, value interface{}, expiration time.Duration) error {
+	return c.shard(key).SetWithExpire(key, value, expiration)
+}
+
+func (c *shardedCache) SetWithCost(key, value interface{}, cost float64) error {
+	return c.shard(key).SetWithCost(key, value, cost)
+}
+
+func (c *shardedCache) SetWithExpireAfterAccess(key, value interface{}, idle time.Duration) error {
+	return c.shard(key).SetWithExpireAfterAccess(key, value, idle)
+}
+
+func (c *shardedCache) SetWithExpireAfterAccessAndLifetime(key, value interface{}, idle, lifetime time.Duration) error {
+	return c.shard(key).SetWithExpireAfterAccessAndLifetime(key, value, idle, lifetime)
+}
+
+func (c *shardedCache) Get(key interface{}) (interface{}, error) {
+	return c.shard(key).Get(key)
+}
+
+func (c *shardedCache) GetCtx(ctx context.Context, key interface{}) (interface{}, error) {
+	return c.shard(key).GetCtx(ctx, key)
+}
+
+func (c *shardedCache) GetIfPresent(key interface{}) (interface{}, error) {
+	return c.shard(key).GetIfPresent(key)
+}
+
+func (c *shardedCache) get(key interface{}, onLoad bool) (interface{}, error) {
+	return c.shard(key).get(key, onLoad)
+}
+
+// GetMany 先在每个分片中查找 keys 再把所有分片未命中的 key 一起加载
+// 设置了 batchLoaderFunc 时 batchLoaderFunc 只会被调用一次
+func (c *shardedCache) GetMany(keys []interface{}) (map[interface{}]interface{}, error) {
+	groups := make(map[shardCache][]interface{})
+	for _, key := range keys {
+		shard := c.shard(key)
+		groups[shard] = append(groups[shard], key)
+	}
+	items := make(map[interface{}]interface{}, len(keys))
+	misses := make(map[shardCache][]interface{})
+	for shard, ks := range groups {
+		m, ms := shard.lookupMany(ks)
+		for k, v := range m {
+			items[k] = v
+		}
+		if len(ms) > 0 {
+			misses[shard] = ms
+		}
+	}
+	if len(misses) == 0 {
+		return items, nil
+	}
+
+	// 每个分片在自己的 goroutine 中加载 最后一个到达的分片调用 batchLoaderFunc
+	call := &batchCall{
+		pending: len(misses),
+		load:    c.shards[0].callBatchLoader,
+		done:    make(chan struct{}),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var err error
+	for shard, ms := range misses {
+		wg.Add(1)
+		go func(shard shardCache, ms []interface{}) {
+			defer wg.Done()
+			member := &batchMember{call: call}
+			defer member.leave()
+			ctx := context.WithValue(context.Background(), batchMemberKey{}, member)
+			m, e := shard.loadMany(ctx, make(map[interface{}]interface{}, len(ms)), ms)
+			mu.Lock()
+			defer mu.Unlock()
+			for k, v := range m {
+				items[k] = v
+			}
+			if e != nil && err == nil {
+				err = e
+			}
+		}(shard, ms)
+	}
+	wg.Wait()
+	return items, err
+}
+
+type batchMemberKey struct{}
+
+// batchCall 把多个分片的批量加载合并成一次调用
+// 每个分片都提交了 key 或者退出之后才会调用 load
+type batchCall struct {
+	mu      sync.Mutex
+	pending int
+	keys    []interface{}
+	load    func(keys []interface{}) (map[interface{}]interface{}, time.Duration, error)
+	done    chan struct{}
+	m       map[interface{}]interface{}
+	elapsed time.Duration
+	err     error
+}
+
+// arrive 提交一个分片的 key 最后一个到达的分片负责加载
+func (b *batchCall) arrive(keys []interface{}) {
+	b.mu.Lock()
+	b.keys = append(b.keys, keys...)
+	b.pending--
+	last := b.pending == 0
+	b.mu.Unlock()
+	if !last {
+		return
+	}
+	if len(b.keys) > 0 {
+		b.m, b.elapsed, b.err = b.load(b.keys)
+	}
+	close(b.done)
+}
+
+// batchMember 是一个分片在 batchCall 中的状态 只在这个分片的 goroutine 中使用
+type batchMember struct {
+	call   *batchCall
+	joined bool
+}
+
+// load 等待合并的调用完成 返回属于这个分片的结果 耗时按照结果的个数分配
+func (m *batchMember) load(keys []interface{}) (map[interface{}]interface{}, time.Duration, error) {
+	m.joined = true
+	m.call.arrive(keys)
+	<-m.call.done
+	if m.call.err != nil {
+		return nil, 0, m.call.err
+	}
+	values := make(map[interface{}]interface{}, len(keys))
+	for _, key := range keys {
+		if v, ok := m.call.m[key]; ok {
+			values[key] = v
+		}
+	}
+	elapsed := m.call.elapsed * time.Duration(len(values)) / time.Duration(max(len(m.call.m), 1))
+	return values, elapsed, nil
+}
+
+// leave 表示这个分片不需要加载 可以重复调用
+func (m *batchMember) leave() {
+	if m.joined {
+		return
+	}
+	m.joined = true
+	m.call.arrive(nil)
+}
+
+func (c *shardedCache) SetMany(items map[interface{}]interface{}) error {
+	groups := make(map[shardCache]map[interface{}]interface{})
+	for k, v := range items {
+		shard := c.shard(k)
+		if groups[shard] == nil {
+			groups[shard] = make(map[interface{}]interface{})
+		}
+		groups[shard][k] = v
+	}
+	for shard, m := range groups {
+		if err := shard.SetMany(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *shardedCache) GetOrSet(key, value interface{}) (interface{}, bool, error) {
+	return c.shard(key).GetOrSet(key, value)
+}
+
+func (c *shardedCache) Compute(key interface{}, fn ComputeFunc) (interface{}, error) {
+	return c.shard(key).Compute(key, fn)
+}
+
+func (c *shardedCache) CompareAndSwap(key, old, new interface{}) bool {
+	return c.shard(key).CompareAndSwap(key, old, new)
+}
+
+func (c *shardedCache) Incr(key interface{}, delta int64) (int64, error) {
+	return c.shard(key).Incr(key, delta)
+}
+
+func (c *shardedCache) IncrFrom(key interface{}, delta int64, initial interface{}) (int64, error) {
+	return c.shard(key).IncrFrom(key, delta, initial)
+}
+
+func (c *shardedCache) Decr(key interface{}, delta int64) (int64, error) {
+	return c.shard(key).Decr(key, delta)
+}
+
+func (c *shardedCache) GetALL(checkExpired bool) map[interface{}]interface{} {
+	items := make(map[interface{}]interface{})
+	for _, shard := range c.shards {
+		for k, v := range shard.GetALL(checkExpired) {
+			items[k] = v
+		}
+	}
+	return items
+}
+
+func (c *shardedCache) Keys(checkExpired bool) []interface{} {
+	var keys []interface{}
+	for _, shard := range c.shards {
+		keys = append(keys, shard.Keys(checkExpired)...)
+	}
+	return keys
+}
+
+func (c *shardedCache) Len(checkExpired bool) int {
+	var length int
+	for _, shard := range c.shards {
+		length += shard.Len(checkExpired)
+	}
+	return length
+}
+
+func (c *shardedCache) Has(key interface{}) bool {
+	return c.shard(key).Has(key)
+}
+
+func (c *shardedCache) Remove(key interface{}) bool {
+	return c.shard(key).Remove(key)
+}
+
+func (c *shardedCache) HitCount() uint64 {
+	return c.sum(Cache.HitCount)
+}
+
+func (c *shardedCache) MissCount() uint64 {
+	return c.sum(Cache.MissCount)
+}
+
+func (c *shardedCache) LookupCount() uint64 {
+	return c.sum(Cache.LookupCount)
+}
+
+func (c *shardedCache) StaleCount() uint64 {
+	return c.sum(Cache.StaleCount)
+}
+
+func (c *shardedCache) NegativeHitCount() uint64 {
+	return c.sum(Cache.NegativeHitCount)
+}
+
+func (c *shardedCache) RemovalCount(cause RemovalCause) uint64 {
+	return c.sum(func(shard Cache) uint64 {
+		return shard.RemovalCount(cause)
+	})
+}
+
+func (c *shardedCache) Stats() StatsSnapshot {
+	var snap StatsSnapshot
+	for _, shard := range c.shards {
+		snap = snap.plus(shard.Stats())
+	}
+	return snap
+}
+
+func (c *shardedCache) HitRate() float64 {
+	hc, mc := c.HitCount(), c.MissCount()
+	total := hc + mc
+	if total == 0 {
+		return 0.0
+	}
+	return float64(hc) / float64(total)
+}
+
+// sum 合并所有分片的统计数据
+func (c *shardedCache) sum(count func(Cache) uint64) uint64 {
+	var total uint64
+	for _, shard := range c.shards {
+		total += count(shard)
+	}
+	return total
+}
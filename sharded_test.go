@@ -0,0 +1,268 @@
+package hyliocache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func buildTestShardedCache(t *testing.T, tp string, size int) Cache {
+	return New(size).
+		EvictType(tp).
+		Shards(4).
+		LoaderFunc(loader).
+		EvictedFunc(getSimpleEvictedFunc(t)).
+		Build()
+}
+
+func TestShardedGet(t *testing.T) {
+	for _, tp := range []string{TypeSimple, TypeLru, TypeLfu, TypeArc} {
+		t.Run(tp, func(t *testing.T) {
+			size := 1000
+			gc := buildTestShardedCache(t, tp, size)
+			testSetCache(t, gc, 100)
+			testGetCache(t, gc, 100)
+			if l := gc.Len(true); l != 100 {
+				t.Fatalf("%v != 100", l)
+			}
+			if n := len(gc.Keys(true)); n != 100 {
+				t.Fatalf("%v != 100", n)
+			}
+			if n := len(gc.GetALL(true)); n != 100 {
+				t.Fatalf("%v != 100", n)
+			}
+			gc.Get("missing")
+			if hc, mc := gc.HitCount(), gc.MissCount(); hc != 100 || mc != 1 {
+				t.Fatalf("hit count %v, miss count %v", hc, mc)
+			}
+		})
+	}
+}
+
+func TestShardedKeyHasher(t *testing.T) {
+	gc := New(16).
+		LRU().
+		Shards(4).
+		KeyHasher(func(key interface{}) uint64 {
+			return uint64(key.(int))
+		}).
+		Build()
+	setItemsByRange(t, gc, 0, 8)
+	shards := gc.(*shardedCache).shards
+	for i := 0; i < 8; i++ {
+		if !shards[i%4].Has(i) {
+			t.Errorf("shard %v should have %v", i%4, i)
+		}
+	}
+	checkItemsByRange(t, gc.Keys(false), gc.GetALL(false), gc.Len(false), 0, 8)
+}
+
+func TestShardedGetMany(t *testing.T) {
+	var calls int
+	gc := New(64).
+		LRU().
+		Shards(4).
+		BatchLoaderFunc(func(keys []interface{}) (map[interface{}]interface{}, error) {
+			calls++
+			m := make(map[interface{}]interface{}, len(keys))
+			for _, k := range keys {
+				m[k] = fmt.Sprint(k)
+			}
+			return m, nil
+		}).
+		Build()
+	keys := make([]interface{}, 0, 32)
+	for i := 0; i < 32; i++ {
+		keys = append(keys, i)
+	}
+	m, err := gc.GetMany(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 32 {
+		t.Fatalf("%v != 32", len(m))
+	}
+	if calls != 1 {
+		t.Fatalf("batch loader should be called once for all shards, not %v", calls)
+	}
+	// 部分命中时未命中的 key 仍然只加载一次
+	for i := 32; i < 48; i++ {
+		keys = append(keys, i)
+	}
+	m, err = gc.GetMany(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 48 || m[40] != "40" {
+		t.Fatalf("unexpected items %v", m)
+	}
+	if calls != 2 {
+		t.Fatalf("batch loader should be called twice, not %v", calls)
+	}
+	if n := gc.Stats().LoadSuccessCount; n != 2 {
+		t.Fatalf("load success count %v != 2", n)
+	}
+}
+
+func TestShardedSharedWorkers(t *testing.T) {
+	gc := New(100).
+		LRU().
+		Shards(4).
+		CleanupInterval(time.Minute).
+		AsyncListeners(64, FullBlock).
+		Build()
+	defer gc.Close()
+	sc := gc.(*shardedCache)
+	d := sc.shards[0].(*LRUCache).dispatcher
+	if d == nil || cap(d.workers[0].queue) != divCeil(64, len(d.workers)) {
+		t.Fatal("shards should share one dispatcher with the configured queue size")
+	}
+	for _, shard := range sc.shards {
+		lc := shard.(*LRUCache)
+		if lc.dispatcher != d {
+			t.Fatal("shards should share one dispatcher")
+		}
+		if lc.janitor != nil {
+			t.Fatal("shards should not start their own janitor")
+		}
+	}
+	if sc.janitor == nil {
+		t.Fatal("sharded cache should start one janitor")
+	}
+}
+
+func TestShardedCleanupInterval(t *testing.T) {
+	clock := NewFakeClock()
+	gc := New(100).
+		LRU().
+		Shards(4).
+		Clock(clock).
+		CleanupInterval(time.Minute).
+		Build()
+	defer gc.Close()
+	for i := 0; i < 20; i++ {
+		gc.SetWithExpire(i, i, time.Second)
+	}
+	clock.Advance(time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for gc.Len(false) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expired items should be cleaned up, %d left", gc.Len(false))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestShardedPurge(t *testing.T) {
+	var visited int
+	gc := New(100).
+		LRU().
+		Shards(4).
+		PurgeVisitorFunc(func(key, value interface{}) {
+			visited++
+		}).
+		Build()
+	setItemsByRange(t, gc, 0, 20)
+	gc.Purge()
+	if n := gc.Len(false); n != 0 || visited != 20 {
+		t.Fatalf("expected empty cache and 20 visited items, got %d items and %d visited", n, visited)
+	}
+}
+
+func TestShardedStats(t *testing.T) {
+	gc := buildTestShardedCache(t, TypeLru, 1000)
+	testSetCache(t, gc, 100)
+	testGetCache(t, gc, 100)
+	gc.Get("missing")
+	snap := gc.Stats()
+	if snap.EntryCount != 101 || snap.HitCount != 100 || snap.LoadSuccessCount != 1 {
+		t.Fatalf("unexpected stats %+v", snap)
+	}
+}
+
+func TestShardedGetManyConcurrent(t *testing.T) {
+	gc := New(1000).
+		LRU().
+		Shards(4).
+		BatchLoaderFunc(func(keys []interface{}) (map[interface{}]interface{}, error) {
+			time.Sleep(time.Millisecond)
+			m := make(map[interface{}]interface{}, len(keys))
+			for _, k := range keys {
+				m[k] = k
+			}
+			return m, nil
+		}).
+		Build()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			// 相邻的 goroutine 的 key 有一半重叠 会等待其他 goroutine 的加载
+			keys := make([]interface{}, 0, 20)
+			for i := g * 10; i < g*10+20; i++ {
+				keys = append(keys, i)
+			}
+			m, err := gc.GetMany(keys)
+			if err != nil || len(m) != 20 {
+				t.Errorf("unexpected result %v %v", m, err)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestShardedSize(t *testing.T) {
+	for _, tc := range []struct {
+		size, shards, n int
+	}{
+		{3, 8, 3},
+		{10, 4, 4},
+		{100, 4, 4},
+	} {
+		gc := New(tc.size).LRU().Shards(tc.shards).Build()
+		if n := len(gc.(*shardedCache).shards); n != tc.n {
+			t.Fatalf("New(%d).Shards(%d) should have %d shards, not %d", tc.size, tc.shards, tc.n, n)
+		}
+		setItemsByRange(t, gc, 0, tc.size*10)
+		if l := gc.Len(false); l != tc.size {
+			t.Fatalf("New(%d).Shards(%d) should hold %d items, not %d", tc.size, tc.shards, tc.size, l)
+		}
+	}
+}
+
+func TestShardedMaxWeight(t *testing.T) {
+	gc := New(100).
+		LRU().
+		Shards(4).
+		Weigher(func(key, value interface{}) int64 {
+			return int64(value.(int))
+		}).
+		MaxWeight(102).
+		Build()
+	var total int64
+	for _, shard := range gc.(*shardedCache).shards {
+		total += shard.(*LRUCache).maxWeight
+	}
+	if total != 102 {
+		t.Fatalf("shard weights should add up to 102, not %d", total)
+	}
+	// 比分片的最大重量重 但不超过整个缓存的最大重量
+	if err := gc.Set("heavy", 40); err != nil {
+		t.Fatalf("item lighter than the whole budget should be accepted, err = %v", err)
+	}
+	if !gc.Has("heavy") {
+		t.Fatal("should have heavy")
+	}
+	if err := gc.Set("too-heavy", 103); err != ItemTooHeavyError {
+		t.Fatalf("item heavier than the whole budget should be rejected, err = %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		gc.Set(i, 3)
+	}
+	// 只有 heavy 所在的分片可以超过自己的最大重量
+	if w := gc.Stats().Weight; w > 102-25+40 {
+		t.Fatalf("total weight %d is over the budget", w)
+	}
+}
@@ -67,6 +67,66 @@ func TestSimpleGetIFPresent(t *testing.T) {
 	testGetIFPresent(t, TypeSimple)
 }
 
+func TestSimpleGetCtx(t *testing.T) {
+	testGetCtx(t, TypeSimple)
+}
+
+func TestSimpleGetMany(t *testing.T) {
+	testGetMany(t, TypeSimple)
+}
+
+func TestSimpleCompute(t *testing.T) {
+	testCompute(t, TypeSimple)
+}
+
+func TestSimpleRefreshAfterWrite(t *testing.T) {
+	testRefreshAfterWrite(t, TypeSimple)
+}
+
+func TestSimpleStaleIfError(t *testing.T) {
+	testStaleIfError(t, TypeSimple)
+}
+
+func TestSimpleNegativeTTL(t *testing.T) {
+	testNegativeTTL(t, TypeSimple)
+}
+
+func TestSimpleWeigher(t *testing.T) {
+	testWeigher(t, TypeSimple)
+}
+
+func TestSimpleExpireAfterAccess(t *testing.T) {
+	testExpireAfterAccess(t, TypeSimple)
+}
+
+func TestSimpleExpiry(t *testing.T) {
+	testExpiry(t, TypeSimple)
+}
+
+func TestSimpleCleanupInterval(t *testing.T) {
+	testCleanupInterval(t, TypeSimple)
+}
+
+func TestSimpleEvictExpiredFirst(t *testing.T) {
+	testEvictExpiredFirst(t, TypeSimple)
+}
+
+func TestSimpleRemovalListener(t *testing.T) {
+	testRemovalListener(t, TypeSimple)
+}
+
+func TestSimpleAsyncListeners(t *testing.T) {
+	testAsyncListeners(t, TypeSimple)
+}
+
+func TestSimplePurge(t *testing.T) {
+	testPurge(t, TypeSimple)
+}
+
+func TestSimpleStats(t *testing.T) {
+	testStats(t, TypeSimple)
+}
+
 func TestSimpleHas(t *testing.T) {
 	gc := buildTestLoadingCacheWithExpiration(t, TypeSimple, 2, 10*time.Millisecond)
 
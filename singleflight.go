@@ -1,7 +1,9 @@
 package hyliocache
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
 )
 
 // singleflight模块提供防止缓存击穿的能力
@@ -11,19 +13,31 @@ import (
 
 // call 表示正在进行中的请求
 type call struct {
-	wg  sync.WaitGroup
-	val interface{}
-	err error
+	done    chan struct{}
+	val     interface{}
+	err     error
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int // 仍在等待结果的请求数
 }
 
 // Group 管理不同key的请求
 type Group struct {
-	cache Cache
-	mu    sync.Mutex
-	m     map[interface{}]*call
+	cache   Cache
+	mu      sync.Mutex
+	m       map[interface{}]*call
+	deduped uint64 // 等待已有请求而没有重复加载的次数
 }
 
 func (g *Group) Do(key interface{}, fn func() (interface{}, error), isWait bool) (interface{}, bool, error) {
+	return g.DoCtx(context.Background(), key, func(context.Context) (interface{}, error) {
+		return fn()
+	}, isWait)
+}
+
+// DoCtx 与 Do 相同 但每个等待者都可以通过自己的 ctx 放弃等待
+// 只有当所有等待者都放弃之后 传给 fn 的 ctx 才会被取消
+func (g *Group) DoCtx(ctx context.Context, key interface{}, fn func(context.Context) (interface{}, error), isWait bool) (interface{}, bool, error) {
 	g.mu.Lock()
 	v, err := g.cache.get(key, true)
 	if err == nil {
@@ -38,35 +52,170 @@ func (g *Group) Do(key interface{}, fn func() (interface{}, error), isWait bool)
 	// 因此进入wait状态
 	// 此时可以将锁释放 这样其他相同的请求也能直接进入到等待状态
 	if c, ok := g.m[key]; ok {
-		g.mu.Unlock()
 		if !isWait {
+			g.mu.Unlock()
 			return nil, false, KeyNotFoundError
 		}
-		c.wg.Wait()
-		return c.val, false, c.err
+		c.waiters++
+		g.mu.Unlock()
+		atomic.AddUint64(&g.deduped, 1)
+		v, err = g.wait(ctx, c, key)
+		return v, false, err
 	}
 	// 如果Group中没有相同的请求
 	// 说明当前请求是第一个获取到资源的请求
 	// 那么它应该继续完成后续的动作
-	c := new(call)
-	c.wg.Add(1)
+	// 加载使用的 ctx 不随发起者一起取消 而是在所有等待者都放弃后才取消
+	c := &call{done: make(chan struct{})}
+	c.ctx, c.cancel = context.WithCancel(context.WithoutCancel(ctx))
 	g.m[key] = c
-	g.mu.Unlock()
-
 	if !isWait {
+		g.mu.Unlock()
 		go g.call(c, key, fn)
 		return nil, false, KeyNotFoundError
 	}
-	v, err = g.call(c, key, fn)
+	c.waiters++
+	g.mu.Unlock()
+
+	// 不可取消的 ctx 不需要额外的 goroutine
+	if ctx.Done() == nil {
+		v, err = g.call(c, key, fn)
+		return v, true, err
+	}
+	go g.call(c, key, fn)
+	v, err = g.wait(ctx, c, key)
 	return v, true, err
 }
 
-func (g *Group) call(c *call, key interface{}, fn func() (interface{}, error)) (interface{}, error) {
-	c.val, c.err = fn()
-	c.wg.Done()
+// Refresh 在后台执行 fn 已经有相同 key 的请求在进行中时直接返回
+// 与 Do 不同 它不会先检查缓存中是否已经存在 key
+func (g *Group) Refresh(key interface{}, fn func(context.Context) (interface{}, error)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.m == nil {
+		g.m = make(map[interface{}]*call)
+	}
+	if _, ok := g.m[key]; ok {
+		return
+	}
+	c := &call{done: make(chan struct{})}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	g.m[key] = c
+	go g.call(c, key, fn)
+}
+
+// wait 等待请求完成或者 ctx 结束
+func (g *Group) wait(ctx context.Context, c *call, key interface{}) (interface{}, error) {
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+	}
+	g.mu.Lock()
+	c.waiters--
+	if c.waiters == 0 {
+		// 已经没有人关心这次加载的结果了
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+		c.cancel()
+	}
+	g.mu.Unlock()
+	return nil, ctx.Err()
+}
+
+func (g *Group) call(c *call, key interface{}, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	c.val, c.err = fn(c.ctx)
+	c.cancel()
+	close(c.done)
 	g.mu.Lock()
-	delete(g.m, key)
+	if g.m[key] == c {
+		delete(g.m, key)
+	}
 	g.mu.Unlock()
 
 	return c.val, c.err
 }
+
+// DoMany 对一组 key 发起一次批量请求
+// 已经有请求在进行中的 key 不会重复加载 而是等待已有的请求完成
+// fn 只会收到当前没有请求在进行中的 key
+func (g *Group) DoMany(ctx context.Context, keys []interface{}, fn func(context.Context, []interface{}) (map[interface{}]interface{}, error)) (map[interface{}]interface{}, error) {
+	values := make(map[interface{}]interface{}, len(keys))
+	owned := make(map[interface{}]*call)
+	waiting := make(map[interface{}]*call)
+	var ownedKeys []interface{}
+
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[interface{}]*call)
+	}
+	// 批量请求由当前请求同步完成 不会被其他等待者取消
+	bctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	for _, key := range keys {
+		if _, ok := values[key]; ok {
+			continue
+		}
+		if _, ok := owned[key]; ok {
+			continue
+		}
+		if _, ok := waiting[key]; ok {
+			continue
+		}
+		if v, err := g.cache.get(key, true); err == nil {
+			values[key] = v
+			continue
+		}
+		if c, ok := g.m[key]; ok {
+			c.waiters++
+			waiting[key] = c
+			atomic.AddUint64(&g.deduped, 1)
+			continue
+		}
+		c := &call{done: make(chan struct{}), ctx: bctx, cancel: cancel, waiters: 1}
+		g.m[key] = c
+		owned[key] = c
+		ownedKeys = append(ownedKeys, key)
+	}
+	g.mu.Unlock()
+
+	var err error
+	if len(ownedKeys) > 0 {
+		var loaded map[interface{}]interface{}
+		loaded, err = fn(bctx, ownedKeys)
+		cancel()
+		g.mu.Lock()
+		for key, c := range owned {
+			if err != nil {
+				c.err = err
+			} else if v, ok := loaded[key]; ok {
+				c.val = v
+				values[key] = v
+			} else {
+				c.err = KeyNotFoundError
+			}
+			close(c.done)
+			if g.m[key] == c {
+				delete(g.m, key)
+			}
+		}
+		g.mu.Unlock()
+	} else {
+		cancel()
+		// 合并加载的其他分片不需要等待当前分片
+		if member, ok := ctx.Value(batchMemberKey{}).(*batchMember); ok {
+			member.leave()
+		}
+	}
+
+	for key, c := range waiting {
+		v, e := g.wait(ctx, c, key)
+		switch {
+		case e == nil:
+			values[key] = v
+		case e != KeyNotFoundError && err == nil:
+			err = e
+		}
+	}
+	return values, err
+}
@@ -1,6 +1,7 @@
 package hyliocache
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -70,3 +71,140 @@ func TestDoDupSuppress(t *testing.T) {
 		t.Errorf("number of calls = %d; want 1", got)
 	}
 }
+
+func TestDoCtxWaiterCancel(t *testing.T) {
+	var g Group
+	g.cache = New(32).Build()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	loadCanceled := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			close(loadCanceled)
+			return nil, ctx.Err()
+		case <-release:
+			return "bar", nil
+		}
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, _, err := g.DoCtx(ctx1, "key", fn, true)
+		errs <- err
+	}()
+	<-started
+	go func() {
+		_, _, err := g.DoCtx(ctx2, "key", fn, true)
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond) // let the second waiter join
+
+	cancel1()
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("err should be %v, not %v", context.Canceled, err)
+	}
+	select {
+	case <-loadCanceled:
+		t.Fatal("load should not be canceled while a waiter remains")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	cancel2()
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("err should be %v, not %v", context.Canceled, err)
+	}
+	select {
+	case <-loadCanceled:
+	case <-time.After(time.Second):
+		t.Fatal("load should be canceled after every waiter gave up")
+	}
+	close(release)
+}
+
+func TestDoCtxDeadline(t *testing.T) {
+	var g Group
+	g.cache = New(32).Build()
+	release := make(chan struct{})
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err := g.DoCtx(ctx, "key", func(context.Context) (interface{}, error) {
+		<-release
+		return "bar", nil
+	}, true)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("err should be %v, not %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestDoManyDupSuppress(t *testing.T) {
+	var g Group
+	g.cache = New(32).Build()
+	c := make(chan string)
+	started := make(chan struct{})
+	go g.Do("a", func() (interface{}, error) {
+		close(started)
+		return <-c, nil
+	}, true)
+	<-started
+
+	var keys []interface{}
+	done := make(chan struct{})
+	var values map[interface{}]interface{}
+	var err error
+	go func() {
+		values, err = g.DoMany(context.Background(), []interface{}{"a", "b"}, func(_ context.Context, ks []interface{}) (map[interface{}]interface{}, error) {
+			keys = ks
+			return map[interface{}]interface{}{"b": "bar"}, nil
+		})
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond) // let DoMany wait for "a"
+	c <- "foo"
+	<-done
+	if err != nil {
+		t.Fatalf("DoMany error: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != "b" {
+		t.Errorf("fn should only load b, not %v", keys)
+	}
+	if values["a"] != "foo" || values["b"] != "bar" {
+		t.Errorf("unexpected values %v", values)
+	}
+}
+
+func TestStatsDedupCount(t *testing.T) {
+	release := make(chan struct{})
+	cache := New(32).
+		LRU().
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			<-release
+			return key, nil
+		}).
+		Build()
+	const n = 10
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			cache.Get("key")
+		}()
+	}
+	deadline := time.Now().Add(time.Second)
+	for cache.Stats().DedupCount != n-1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d deduplicated requests, got %d", n-1, cache.Stats().DedupCount)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	if snap := cache.Stats(); snap.LoadSuccessCount != 1 {
+		t.Fatalf("expected 1 load, got %d", snap.LoadSuccessCount)
+	}
+}
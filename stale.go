@@ -0,0 +1,93 @@
+package hyliocache
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+stale 模块在开启 StaleIfError 之后保留刚刚过期的元素
+当加载器返回错误时 用这些元素代替错误返回
+这些元素保存在 baseCache.stale 中 不占用缓存的容量
+*/
+
+// StaleError 表示返回的值已经过期 Err 是加载器返回的错误
+type StaleError struct {
+	Err error
+}
+
+func (e *StaleError) Error() string {
+	return fmt.Sprintf("stale value served: %v", e.Err)
+}
+
+func (e *StaleError) Unwrap() error {
+	return e.Err
+}
+
+// keepStale 保留过期的元素 调用时需持有 mu
+func (c *baseCache) keepStale(item *cacheItem) {
+	if c.staleIfError == nil || item.expiration == nil {
+		return
+	}
+	if c.stale == nil {
+		c.stale = make(map[interface{}]*cacheItem)
+	}
+	if c.size > 0 && len(c.stale) >= c.size {
+		c.pruneStale()
+	}
+	it := *item
+	it.timers, it.timer = nil, 0
+	c.stale[item.key] = &it
+}
+
+// pruneStale 删除已经超过 staleIfError 的元素
+// 仍然放不下时随机删除一个
+func (c *baseCache) pruneStale() {
+	now := c.clock.Now()
+	for key, item := range c.stale {
+		if c.isTooStale(item, now) {
+			delete(c.stale, key)
+		}
+	}
+	if len(c.stale) < c.size {
+		return
+	}
+	for key := range c.stale {
+		delete(c.stale, key)
+		return
+	}
+}
+
+func (c *baseCache) isTooStale(item *cacheItem, now time.Time) bool {
+	return item.expiration.Add(*c.staleIfError).Before(now)
+}
+
+// staleValue 返回 key 对应的过期的值
+func (c *baseCache) staleValue(key interface{}) (interface{}, bool) {
+	if c.staleIfError == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.unlock()
+	item, ok := c.stale[key]
+	if !ok {
+		return nil, false
+	}
+	if c.isTooStale(item, c.clock.Now()) {
+		delete(c.stale, key)
+		return nil, false
+	}
+	return item.value, true
+}
+
+// serveStale 在加载失败时尝试返回过期的值
+func (c *baseCache) serveStale(key interface{}, err error) (interface{}, error) {
+	if err == KeyNotFoundError {
+		return nil, err
+	}
+	if v, ok := c.staleValue(key); ok {
+		c.stats.IncrStaleCount()
+		return v, &StaleError{Err: err}
+	}
+	return nil, err
+}
@@ -1,22 +1,35 @@
 package hyliocache
 
-import "sync/atomic"
+import (
+	"sync/atomic"
+	"time"
+)
 
 type statsAccessor interface {
 	HitCount() uint64
 	MissCount() uint64
 	LookupCount() uint64
 	HitRate() float64
+	StaleCount() uint64
+	NegativeHitCount() uint64
+	RemovalCount(cause RemovalCause) uint64
+	Stats() StatsSnapshot
 }
 
 /*
 cache的统计数据
-包括命中次数和miss次数
+包括命中次数和miss次数 删除次数 以及加载的次数和耗时
 */
 
 type stats struct {
-	hitCount  uint64
-	missCount uint64
+	hitCount         uint64
+	missCount        uint64
+	staleCount       uint64 // 加载失败时返回过期值的次数
+	negativeHitCount uint64 // 直接返回缓存的错误的次数
+	removalCount     [removalCauses]uint64
+	loadSuccessCount uint64
+	loadFailureCount uint64
+	totalLoadTime    int64 // 纳秒
 }
 
 // IncrHitCount increment hit count
@@ -29,6 +42,31 @@ func (s *stats) IncrMissCount() uint64 {
 	return atomic.AddUint64(&s.missCount, 1)
 }
 
+// IncrStaleCount increment stale count
+func (s *stats) IncrStaleCount() uint64 {
+	return atomic.AddUint64(&s.staleCount, 1)
+}
+
+// IncrNegativeHitCount increment negative hit count
+func (s *stats) IncrNegativeHitCount() uint64 {
+	return atomic.AddUint64(&s.negativeHitCount, 1)
+}
+
+// IncrRemovalCount increment removal count of cause
+func (s *stats) IncrRemovalCount(cause RemovalCause) uint64 {
+	return atomic.AddUint64(&s.removalCount[cause], 1)
+}
+
+// RecordLoad records a load that took elapsed and returned err
+func (s *stats) RecordLoad(elapsed time.Duration, err error) {
+	if err == nil {
+		atomic.AddUint64(&s.loadSuccessCount, 1)
+	} else {
+		atomic.AddUint64(&s.loadFailureCount, 1)
+	}
+	atomic.AddInt64(&s.totalLoadTime, int64(elapsed))
+}
+
 // HitCount returns hit count
 func (s *stats) HitCount() uint64 {
 	return atomic.LoadUint64(&s.hitCount)
@@ -39,6 +77,24 @@ func (s *stats) MissCount() uint64 {
 	return atomic.LoadUint64(&s.missCount)
 }
 
+// StaleCount returns how many stale values were served
+func (s *stats) StaleCount() uint64 {
+	return atomic.LoadUint64(&s.staleCount)
+}
+
+// NegativeHitCount returns how many cached loader errors were served
+func (s *stats) NegativeHitCount() uint64 {
+	return atomic.LoadUint64(&s.negativeHitCount)
+}
+
+// RemovalCount returns how many items were removed because of cause
+func (s *stats) RemovalCount(cause RemovalCause) uint64 {
+	if cause < 0 || cause >= removalCauses {
+		return 0
+	}
+	return atomic.LoadUint64(&s.removalCount[cause])
+}
+
 // LookupCount returns lookup count
 func (s *stats) LookupCount() uint64 {
 	return s.HitCount() + s.MissCount()
@@ -52,3 +108,123 @@ func (s *stats) HitRate() float64 {
 	}
 	return float64(hc) / float64(total)
 }
+
+// snapshot 返回计数器的当前值 不包括 DedupCount EntryCount 和 Weight
+func (s *stats) snapshot() StatsSnapshot {
+	snap := StatsSnapshot{
+		HitCount:         s.HitCount(),
+		MissCount:        s.MissCount(),
+		StaleCount:       s.StaleCount(),
+		NegativeHitCount: s.NegativeHitCount(),
+		LoadSuccessCount: atomic.LoadUint64(&s.loadSuccessCount),
+		LoadFailureCount: atomic.LoadUint64(&s.loadFailureCount),
+		TotalLoadTime:    time.Duration(atomic.LoadInt64(&s.totalLoadTime)),
+	}
+	for i := range snap.Removals {
+		snap.Removals[i] = atomic.LoadUint64(&s.removalCount[i])
+	}
+	return snap
+}
+
+// Stats 返回统计数据的快照
+func (c *baseCache) Stats() StatsSnapshot {
+	snap := c.stats.snapshot()
+	snap.DedupCount = atomic.LoadUint64(&c.group.deduped)
+	c.mu.RLock()
+	snap.EntryCount = c.store.length()
+	snap.Weight = c.weight
+	c.mu.RUnlock()
+	return snap
+}
+
+// StatsSnapshot 是某一时刻的统计数据 它是一个值 不会随着缓存的变化而变化
+type StatsSnapshot struct {
+	HitCount         uint64
+	MissCount        uint64
+	StaleCount       uint64 // 加载失败时返回过期值的次数
+	NegativeHitCount uint64 // 直接返回缓存的错误的次数
+	// Removals 按照原因统计的删除次数 下标是 RemovalCause
+	Removals         [removalCauses]uint64
+	LoadSuccessCount uint64
+	LoadFailureCount uint64
+	TotalLoadTime    time.Duration // 所有加载的总耗时 批量加载算作一次
+	DedupCount       uint64        // singleflight 合并掉的请求数
+	EntryCount       int           // 当前的元素个数 包括还没有被删除的过期元素
+	Weight           int64         // 当前的总重量
+}
+
+func (s StatsSnapshot) LookupCount() uint64 {
+	return s.HitCount + s.MissCount
+}
+
+func (s StatsSnapshot) HitRate() float64 {
+	if s.LookupCount() == 0 {
+		return 0.0
+	}
+	return float64(s.HitCount) / float64(s.LookupCount())
+}
+
+// RemovalCount 返回因为 cause 被删除的次数
+func (s StatsSnapshot) RemovalCount(cause RemovalCause) uint64 {
+	if cause < 0 || cause >= removalCauses {
+		return 0
+	}
+	return s.Removals[cause]
+}
+
+// EvictionCount 返回因为过期或者超过容量被删除的次数
+func (s StatsSnapshot) EvictionCount() uint64 {
+	return s.Removals[RemovalExpired] + s.Removals[RemovalSize]
+}
+
+func (s StatsSnapshot) LoadCount() uint64 {
+	return s.LoadSuccessCount + s.LoadFailureCount
+}
+
+// AverageLoadPenalty 返回每次加载的平均耗时
+func (s StatsSnapshot) AverageLoadPenalty() time.Duration {
+	n := s.LoadCount()
+	if n == 0 {
+		return 0
+	}
+	return s.TotalLoadTime / time.Duration(n)
+}
+
+// Minus 返回从 prev 到 s 之间的增量 计数器不会小于 0
+// EntryCount 和 Weight 是当前值 保持 s 的值
+func (s StatsSnapshot) Minus(prev StatsSnapshot) StatsSnapshot {
+	d := StatsSnapshot{
+		HitCount:         subUint64(s.HitCount, prev.HitCount),
+		MissCount:        subUint64(s.MissCount, prev.MissCount),
+		StaleCount:       subUint64(s.StaleCount, prev.StaleCount),
+		NegativeHitCount: subUint64(s.NegativeHitCount, prev.NegativeHitCount),
+		LoadSuccessCount: subUint64(s.LoadSuccessCount, prev.LoadSuccessCount),
+		LoadFailureCount: subUint64(s.LoadFailureCount, prev.LoadFailureCount),
+		TotalLoadTime:    time.Duration(subUint64(uint64(s.TotalLoadTime), uint64(prev.TotalLoadTime))),
+		DedupCount:       subUint64(s.DedupCount, prev.DedupCount),
+		EntryCount:       s.EntryCount,
+		Weight:           s.Weight,
+	}
+	for i := range d.Removals {
+		d.Removals[i] = subUint64(s.Removals[i], prev.Removals[i])
+	}
+	return d
+}
+
+// plus 合并两个快照 用于汇总各个分片的统计数据
+func (s StatsSnapshot) plus(o StatsSnapshot) StatsSnapshot {
+	s.HitCount += o.HitCount
+	s.MissCount += o.MissCount
+	s.StaleCount += o.StaleCount
+	s.NegativeHitCount += o.NegativeHitCount
+	s.LoadSuccessCount += o.LoadSuccessCount
+	s.LoadFailureCount += o.LoadFailureCount
+	s.TotalLoadTime += o.TotalLoadTime
+	s.DedupCount += o.DedupCount
+	s.EntryCount += o.EntryCount
+	s.Weight += o.Weight
+	for i := range s.Removals {
+		s.Removals[i] += o.Removals[i]
+	}
+	return s
+}
@@ -0,0 +1,72 @@
+package hyliocache
+
+/*
+twoqueue 模块实现 2Q 淘汰策略
+新的元素先进入 FIFO 队列 A1in 从 A1in 被淘汰的 key 记录在幽灵队列 A1out 中
+只有在 A1out 中的 key 再次被写入时才会进入 LRU 队列 Am
+只被访问过一次的元素 (比如扫描) 不会挤掉 Am 中的元素
+*/
+
+const (
+	DefaultTwoQueueRecentRatio = 0.25 // A1in 占容量的比例
+	DefaultTwoQueueGhostRatio  = 0.5  // A1out 能记录的 key 的数量与容量的比例
+)
+
+type twoQueue struct {
+	recentCap int
+	ghostCap  int
+	recent    *arcList // A1in
+	ghost     *arcList // A1out
+	frequent  *arcList // Am
+}
+
+func newTwoQueue(size int, recentRatio, ghostRatio float64) *twoQueue {
+	return &twoQueue{
+		recentCap: max(1, int(float64(size)*recentRatio)),
+		ghostCap:  max(1, int(float64(size)*ghostRatio)),
+		recent:    newArcList(),
+		ghost:     newArcList(),
+		frequent:  newArcList(),
+	}
+}
+
+func (q *twoQueue) OnInsert(key interface{}) {
+	if ele := q.ghost.Get(key); ele != nil {
+		q.ghost.Remove(key, ele)
+		q.frequent.PushFront(key)
+		return
+	}
+	q.recent.PushFront(key)
+}
+
+// OnAccess A1in 是 FIFO 队列 其中的元素被访问时不会移动
+func (q *twoQueue) OnAccess(key interface{}) {
+	if ele := q.frequent.Get(key); ele != nil {
+		q.frequent.MoveToFront(ele)
+	}
+}
+
+func (q *twoQueue) OnRemove(key interface{}) {
+	if ele := q.recent.Get(key); ele != nil {
+		q.recent.Remove(key, ele)
+	} else if ele := q.frequent.Get(key); ele != nil {
+		q.frequent.Remove(key, ele)
+	}
+}
+
+// Victim A1in 超出容量时从 A1in 淘汰 并把 key 记录到 A1out 中
+// 否则从 Am 的末端淘汰
+func (q *twoQueue) Victim() (interface{}, bool) {
+	if q.recent.Len() > q.recentCap || (q.frequent.Len() == 0 && q.recent.Len() > 0) {
+		key := q.recent.RemoveTail()
+		q.ghost.PushFront(key)
+		for q.ghost.Len() > q.ghostCap {
+			q.ghost.RemoveTail()
+		}
+		return key, true
+	}
+	if q.frequent.Len() > 0 {
+		return q.frequent.Back(), true
+	}
+	return nil, false
+}
@@ -0,0 +1,187 @@
+package hyliocache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTwoQueueGet(t *testing.T) {
+	size := 1000
+	gc := buildTestCache(t, TypeTwoQueue, size)
+	testSetCache(t, gc, size)
+	testGetCache(t, gc, size)
+}
+
+func TestLoadingTwoQueueGet(t *testing.T) {
+	size := 1000
+	gc := buildTestLoadingCache(t, TypeTwoQueue, size, loader)
+	testGetCache(t, gc, size)
+}
+
+func TestTwoQueueLength(t *testing.T) {
+	gc := buildTestLoadingCache(t, TypeTwoQueue, 1000, loader)
+	gc.Get("test1")
+	gc.Get("test2")
+	length := gc.Len(true)
+	expectedLength := 2
+	if length != expectedLength {
+		t.Errorf("Expected length is %v, not %v", length, expectedLength)
+	}
+}
+
+func TestTwoQueueEvictItem(t *testing.T) {
+	cacheSize := 10
+	numbers := 11
+	gc := buildTestLoadingCache(t, TypeTwoQueue, cacheSize, loader)
+
+	for i := 0; i < numbers; i++ {
+		_, err := gc.Get(fmt.Sprintf("Key-%d", i))
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func TestTwoQueueGetIFPresent(t *testing.T) {
+	testGetIFPresent(t, TypeTwoQueue)
+}
+
+func TestTwoQueueGetCtx(t *testing.T) {
+	testGetCtx(t, TypeTwoQueue)
+}
+
+func TestTwoQueueGetMany(t *testing.T) {
+	testGetMany(t, TypeTwoQueue)
+}
+
+func TestTwoQueueCompute(t *testing.T) {
+	testCompute(t, TypeTwoQueue)
+}
+
+func TestTwoQueueRefreshAfterWrite(t *testing.T) {
+	testRefreshAfterWrite(t, TypeTwoQueue)
+}
+
+func TestTwoQueueStaleIfError(t *testing.T) {
+	testStaleIfError(t, TypeTwoQueue)
+}
+
+func TestTwoQueueNegativeTTL(t *testing.T) {
+	testNegativeTTL(t, TypeTwoQueue)
+}
+
+func TestTwoQueueWeigher(t *testing.T) {
+	testWeigher(t, TypeTwoQueue)
+}
+
+func TestTwoQueueExpireAfterAccess(t *testing.T) {
+	testExpireAfterAccess(t, TypeTwoQueue)
+}
+
+func TestTwoQueueExpiry(t *testing.T) {
+	testExpiry(t, TypeTwoQueue)
+}
+
+func TestTwoQueueCleanupInterval(t *testing.T) {
+	testCleanupInterval(t, TypeTwoQueue)
+}
+
+func TestTwoQueueEvictExpiredFirst(t *testing.T) {
+	testEvictExpiredFirst(t, TypeTwoQueue)
+}
+
+func TestTwoQueueRemovalListener(t *testing.T) {
+	testRemovalListener(t, TypeTwoQueue)
+}
+
+func TestTwoQueueAsyncListeners(t *testing.T) {
+	testAsyncListeners(t, TypeTwoQueue)
+}
+
+func TestTwoQueuePurge(t *testing.T) {
+	testPurge(t, TypeTwoQueue)
+}
+
+func TestTwoQueueStats(t *testing.T) {
+	testStats(t, TypeTwoQueue)
+}
+
+func TestTwoQueueHas(t *testing.T) {
+	gc := buildTestLoadingCacheWithExpiration(t, TypeTwoQueue, 2, 10*time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			gc.Get("test1")
+			gc.Get("test2")
+
+			if gc.Has("test0") {
+				t.Fatal("should not have test0")
+			}
+			if !gc.Has("test1") {
+				t.Fatal("should have test1")
+			}
+			if !gc.Has("test2") {
+				t.Fatal("should have test2")
+			}
+
+			time.Sleep(20 * time.Millisecond)
+
+			if gc.Has("test0") {
+				t.Fatal("should not have test0")
+			}
+			if gc.Has("test1") {
+				t.Fatal("should not have test1")
+			}
+			if gc.Has("test2") {
+				t.Fatal("should not have test2")
+			}
+		})
+	}
+}
+
+func TestTwoQueueScanResistance(t *testing.T) {
+	size := 10
+	var evicted int
+	gc := New(size).
+		TwoQueue().
+		TwoQueueRatios(0.2, 0.5).
+		EvictedFunc(func(key, value interface{}) {
+			evicted++
+		}).
+		Build()
+
+	gc.Set("hot", "value")
+	setItemsByRange(t, gc, 0, size)
+	if gc.Has("hot") {
+		t.Fatal("hot should be evicted from A1in")
+	}
+	// A1out 中的 key 再次被写入时进入 Am
+	gc.Set("hot", "value")
+	setItemsByRange(t, gc, 100, 200)
+	if !gc.Has("hot") {
+		t.Fatal("hot should survive the scan")
+	}
+	if l := gc.Len(false); l != size {
+		t.Fatalf("%v != %v", l, size)
+	}
+	if evicted != 102 {
+		t.Fatalf("evicted should be called 102 times, not %v", evicted)
+	}
+}
+
+func TestTwoQueueSetWithExpire(t *testing.T) {
+	clock := NewFakeClock()
+	gc := New(4).
+		TwoQueue().
+		Clock(clock).
+		Build()
+	gc.SetWithExpire("key", "value", time.Minute)
+	if !gc.Has("key") {
+		t.Fatal("should have key")
+	}
+	clock.Advance(2 * time.Minute)
+	if _, err := gc.Get("key"); err != KeyNotFoundError {
+		t.Fatalf("err should be KeyNotFoundError, not %v", err)
+	}
+}
@@ -0,0 +1,471 @@
+// Package typed 在 hyliocache 之上提供类型安全的泛型缓存接口
+// 它只是 interface{} 版本的包装 值在内部仍然以 interface{} 保存 因此不能避免装箱
+// 不装箱地保存值需要为每种类型实现一套存储 不在这个包的范围内 这里只提供编译期的类型检查
+package typed
+
+import (
+	"context"
+	"time"
+
+	hyliocache "github.com/hylio/Cache"
+)
+
+const (
+	TypeSimple = hyliocache.TypeSimple
+	TypeLru    = hyliocache.TypeLru
+	TypeLfu    = hyliocache.TypeLfu
+	TypeArc    = hyliocache.TypeArc
+
+	TypeWTinyLFU = hyliocache.TypeWTinyLFU
+	TypeTwoQueue = hyliocache.TypeTwoQueue
+	TypeS3FIFO   = hyliocache.TypeS3FIFO
+	TypeClock    = hyliocache.TypeClock
+	TypeClockPro = hyliocache.TypeClockPro
+	TypeLIRS     = hyliocache.TypeLIRS
+	TypeGDSF     = hyliocache.TypeGDSF
+)
+
+// NeverExpire 表示元素不会过期
+const NeverExpire = hyliocache.NeverExpire
+
+const (
+	RemovalExplicit = hyliocache.RemovalExplicit
+	RemovalExpired  = hyliocache.RemovalExpired
+	RemovalSize     = hyliocache.RemovalSize
+	RemovalReplaced = hyliocache.RemovalReplaced
+	RemovalCleared  = hyliocache.RemovalCleared
+
+	FullBlock  = hyliocache.FullBlock
+	FullDrop   = hyliocache.FullDrop
+	FullInline = hyliocache.FullInline
+)
+
+type (
+	StaleError     = hyliocache.StaleError
+	EvictionPolicy = hyliocache.EvictionPolicy
+	PolicyFactory  = hyliocache.PolicyFactory
+	RemovalCause   = hyliocache.RemovalCause
+	FullPolicy     = hyliocache.FullPolicy
+	StatsSnapshot  = hyliocache.StatsSnapshot
+)
+
+// RegisterPolicy 注册自定义的淘汰策略 策略收到的 key 是 K 类型的值
+func RegisterPolicy(name string, factory PolicyFactory) {
+	hyliocache.RegisterPolicy(name, factory)
+}
+
+var (
+	KeyNotFoundError     = hyliocache.KeyNotFoundError
+	ValueNotIntegerError = hyliocache.ValueNotIntegerError
+	ItemTooHeavyError    = hyliocache.ItemTooHeavyError
+)
+
+type Cache[K comparable, V any] interface {
+	Set(key K, value V) error
+	SetWithExpire(key K, value V, expiration time.Duration) error
+	SetWithCost(key K, value V, cost float64) error
+	SetWithExpireAfterAccess(key K, value V, idle time.Duration) error
+	SetWithExpireAfterAccessAndLifetime(key K, value V, idle, lifetime time.Duration) error
+	Get(key K) (V, error)
+	GetCtx(ctx context.Context, key K) (V, error)
+	GetALL(checkExpired bool) map[K]V
+	GetIfPresent(key K) (V, error)
+	GetMany(keys []K) (map[K]V, error)
+	SetMany(items map[K]V) error
+	GetOrSet(key K, value V) (V, bool, error)
+	Compute(key K, fn ComputeFunc[V]) (V, error)
+	CompareAndSwap(key K, old, new V) bool
+	Incr(key K, delta int64) (int64, error)
+	Decr(key K, delta int64) (int64, error)
+	Keys(checkExpired bool) []K
+	Len(checkExpired bool) int
+	Has(key K) bool
+	Remove(key K) bool
+	Purge()
+	Close()
+	statsAccessor
+}
+
+type statsAccessor interface {
+	HitCount() uint64
+	MissCount() uint64
+	LookupCount() uint64
+	HitRate() float64
+	StaleCount() uint64
+	NegativeHitCount() uint64
+	RemovalCount(cause RemovalCause) uint64
+	Stats() StatsSnapshot
+}
+
+type (
+	LoaderFunc[K comparable, V any]       func(K) (V, error)
+	LoaderExpireFunc[K comparable, V any] func(K) (V, *time.Duration, error)
+	EvictedFunc[K comparable, V any]      func(K, V)
+	AddedFunc[K comparable, V any]        func(K, V)
+	RemovalListener[K comparable, V any]  func(K, V, RemovalCause)
+	PurgeVisitorFunc[K comparable, V any] func(K, V)
+
+	LoaderCtxFunc[K comparable, V any]       func(context.Context, K) (V, error)
+	LoaderExpireCtxFunc[K comparable, V any] func(context.Context, K) (V, *time.Duration, error)
+	BatchLoaderFunc[K comparable, V any]     func([]K) (map[K]V, error)
+	ComputeFunc[V any]                       func(old V, exists bool) (V, bool)
+	Weigher[K comparable, V any]             func(K, V) int64
+)
+
+// Expiry 是 hyliocache.Expiry 的泛型版本
+type Expiry[K comparable, V any] interface {
+	ExpireAfterCreate(key K, value V, now time.Time) time.Duration
+	ExpireAfterUpdate(key K, value V, now time.Time, remaining time.Duration) time.Duration
+	ExpireAfterRead(key K, value V, now time.Time, remaining time.Duration) time.Duration
+}
+
+// expiry 把 Expiry[K, V] 转换成 hyliocache.Expiry
+type expiry[K comparable, V any] struct {
+	e Expiry[K, V]
+}
+
+func (e expiry[K, V]) ExpireAfterCreate(key, value interface{}, now time.Time) time.Duration {
+	return e.e.ExpireAfterCreate(key.(K), valueOf[V](value), now)
+}
+
+func (e expiry[K, V]) ExpireAfterUpdate(key, value interface{}, now time.Time, remaining time.Duration) time.Duration {
+	return e.e.ExpireAfterUpdate(key.(K), valueOf[V](value), now, remaining)
+}
+
+func (e expiry[K, V]) ExpireAfterRead(key, value interface{}, now time.Time, remaining time.Duration) time.Duration {
+	return e.e.ExpireAfterRead(key.(K), valueOf[V](value), now, remaining)
+}
+
+// CacheBuilder 包装了 hyliocache.CacheBuilder
+// 把带类型的回调函数转换成 interface{} 版本
+type CacheBuilder[K comparable, V any] struct {
+	cb *hyliocache.CacheBuilder
+}
+
+func New[K comparable, V any](size int) *CacheBuilder[K, V] {
+	return &CacheBuilder[K, V]{cb: hyliocache.New(size)}
+}
+
+func (b *CacheBuilder[K, V]) Clock(clock hyliocache.Clock) *CacheBuilder[K, V] {
+	b.cb.Clock(clock)
+	return b
+}
+
+func (b *CacheBuilder[K, V]) EvictType(tp string) *CacheBuilder[K, V] {
+	b.cb.EvictType(tp)
+	return b
+}
+
+func (b *CacheBuilder[K, V]) Simple() *CacheBuilder[K, V] {
+	return b.EvictType(TypeSimple)
+}
+
+func (b *CacheBuilder[K, V]) LRU() *CacheBuilder[K, V] {
+	return b.EvictType(TypeLru)
+}
+
+func (b *CacheBuilder[K, V]) LFU() *CacheBuilder[K, V] {
+	return b.EvictType(TypeLfu)
+}
+
+func (b *CacheBuilder[K, V]) ARC() *CacheBuilder[K, V] {
+	return b.EvictType(TypeArc)
+}
+
+func (b *CacheBuilder[K, V]) WTinyLFU() *CacheBuilder[K, V] {
+	return b.EvictType(TypeWTinyLFU)
+}
+
+func (b *CacheBuilder[K, V]) TwoQueue() *CacheBuilder[K, V] {
+	return b.EvictType(TypeTwoQueue)
+}
+
+func (b *CacheBuilder[K, V]) TwoQueueRatios(recentRatio, ghostRatio float64) *CacheBuilder[K, V] {
+	b.cb.TwoQueueRatios(recentRatio, ghostRatio)
+	return b
+}
+
+func (b *CacheBuilder[K, V]) S3FIFO() *CacheBuilder[K, V] {
+	return b.EvictType(TypeS3FIFO)
+}
+
+func (b *CacheBuilder[K, V]) GDSF() *CacheBuilder[K, V] {
+	return b.EvictType(TypeGDSF)
+}
+
+func (b *CacheBuilder[K, V]) HIRRatio(ratio float64) *CacheBuilder[K, V] {
+	b.cb.HIRRatio(ratio)
+	return b
+}
+
+func (b *CacheBuilder[K, V]) FrequencyDecay(interval time.Duration) *CacheBuilder[K, V] {
+	b.cb.FrequencyDecay(interval)
+	return b
+}
+
+func (b *CacheBuilder[K, V]) LoaderFunc(loaderFunc LoaderFunc[K, V]) *CacheBuilder[K, V] {
+	b.cb.LoaderFunc(func(k interface{}) (interface{}, error) {
+		return loaderFunc(k.(K))
+	})
+	return b
+}
+
+func (b *CacheBuilder[K, V]) LoaderExpireFunc(expireFunc LoaderExpireFunc[K, V]) *CacheBuilder[K, V] {
+	b.cb.LoaderExpireFunc(func(k interface{}) (interface{}, *time.Duration, error) {
+		return expireFunc(k.(K))
+	})
+	return b
+}
+
+func (b *CacheBuilder[K, V]) LoaderCtxFunc(loaderFunc LoaderCtxFunc[K, V]) *CacheBuilder[K, V] {
+	b.cb.LoaderCtxFunc(func(ctx context.Context, k interface{}) (interface{}, error) {
+		return loaderFunc(ctx, k.(K))
+	})
+	return b
+}
+
+func (b *CacheBuilder[K, V]) LoaderExpireCtxFunc(expireFunc LoaderExpireCtxFunc[K, V]) *CacheBuilder[K, V] {
+	b.cb.LoaderExpireCtxFunc(func(ctx context.Context, k interface{}) (interface{}, *time.Duration, error) {
+		return expireFunc(ctx, k.(K))
+	})
+	return b
+}
+
+func (b *CacheBuilder[K, V]) BatchLoaderFunc(batchLoaderFunc BatchLoaderFunc[K, V]) *CacheBuilder[K, V] {
+	b.cb.BatchLoaderFunc(func(ks []interface{}) (map[interface{}]interface{}, error) {
+		keys := make([]K, 0, len(ks))
+		for _, k := range ks {
+			keys = append(keys, k.(K))
+		}
+		m, err := batchLoaderFunc(keys)
+		if err != nil {
+			return nil, err
+		}
+		items := make(map[interface{}]interface{}, len(m))
+		for k, v := range m {
+			items[k] = v
+		}
+		return items, nil
+	})
+	return b
+}
+
+func (b *CacheBuilder[K, V]) EvictedFunc(evictedFunc EvictedFunc[K, V]) *CacheBuilder[K, V] {
+	b.cb.EvictedFunc(func(k, v interface{}) {
+		evictedFunc(k.(K), valueOf[V](v))
+	})
+	return b
+}
+
+func (b *CacheBuilder[K, V]) RemovalListener(listener RemovalListener[K, V]) *CacheBuilder[K, V] {
+	b.cb.RemovalListener(func(k, v interface{}, cause RemovalCause) {
+		listener(k.(K), valueOf[V](v), cause)
+	})
+	return b
+}
+
+func (b *CacheBuilder[K, V]) PurgeVisitorFunc(visitor PurgeVisitorFunc[K, V]) *CacheBuilder[K, V] {
+	b.cb.PurgeVisitorFunc(func(k, v interface{}) {
+		visitor(k.(K), valueOf[V](v))
+	})
+	return b
+}
+
+func (b *CacheBuilder[K, V]) AsyncListeners(queueSize int, full FullPolicy) *CacheBuilder[K, V] {
+	b.cb.AsyncListeners(queueSize, full)
+	return b
+}
+
+func (b *CacheBuilder[K, V]) AddedFunc(addedFunc AddedFunc[K, V]) *CacheBuilder[K, V] {
+	b.cb.AddedFunc(func(k, v interface{}) {
+		addedFunc(k.(K), valueOf[V](v))
+	})
+	return b
+}
+
+func (b *CacheBuilder[K, V]) Expiration(expiration time.Duration) *CacheBuilder[K, V] {
+	b.cb.Expiration(expiration)
+	return b
+}
+
+func (b *CacheBuilder[K, V]) ExpireAfterAccess(d time.Duration) *CacheBuilder[K, V] {
+	b.cb.ExpireAfterAccess(d)
+	return b
+}
+
+func (b *CacheBuilder[K, V]) Expiry(e Expiry[K, V]) *CacheBuilder[K, V] {
+	b.cb.Expiry(expiry[K, V]{e})
+	return b
+}
+
+func (b *CacheBuilder[K, V]) CleanupInterval(interval time.Duration) *CacheBuilder[K, V] {
+	b.cb.CleanupInterval(interval)
+	return b
+}
+
+func (b *CacheBuilder[K, V]) RefreshAfterWrite(d time.Duration) *CacheBuilder[K, V] {
+	b.cb.RefreshAfterWrite(d)
+	return b
+}
+
+func (b *CacheBuilder[K, V]) StaleIfError(maxStale time.Duration) *CacheBuilder[K, V] {
+	b.cb.StaleIfError(maxStale)
+	return b
+}
+
+func (b *CacheBuilder[K, V]) NegativeTTL(d time.Duration) *CacheBuilder[K, V] {
+	b.cb.NegativeTTL(d)
+	return b
+}
+
+func (b *CacheBuilder[K, V]) Weigher(weigher Weigher[K, V]) *CacheBuilder[K, V] {
+	b.cb.Weigher(func(k, v interface{}) int64 {
+		return weigher(k.(K), valueOf[V](v))
+	})
+	return b
+}
+
+func (b *CacheBuilder[K, V]) MaxWeight(n int64) *CacheBuilder[K, V] {
+	b.cb.MaxWeight(n)
+	return b
+}
+
+func (b *CacheBuilder[K, V]) Shards(n int) *CacheBuilder[K, V] {
+	b.cb.Shards(n)
+	return b
+}
+
+func (b *CacheBuilder[K, V]) KeyHasher(hasher func(K) uint64) *CacheBuilder[K, V] {
+	b.cb.KeyHasher(func(k interface{}) uint64 {
+		return hasher(k.(K))
+	})
+	return b
+}
+
+func (b *CacheBuilder[K, V]) Build() Cache[K, V] {
+	return &cache[K, V]{Cache: b.cb.Build()}
+}
+
+// cache 把 hyliocache.Cache 的结果转换成对应的类型
+type cache[K comparable, V any] struct {
+	hyliocache.Cache
+}
+
+func (c *cache[K, V]) Set(key K, value V) error {
+	return c.Cache.Set(key, value)
+}
+
+func (c *cache[K, V]) SetWithExpire(key K, value V, expiration time.Duration) error {
+	return c.Cache.SetWithExpire(key, value, expiration)
+}
+
+func (c *cache[K, V]) SetWithCost(key K, value V, cost float64) error {
+	return c.Cache.SetWithCost(key, value, cost)
+}
+
+func (c *cache[K, V]) SetWithExpireAfterAccess(key K, value V, idle time.Duration) error {
+	return c.Cache.SetWithExpireAfterAccess(key, value, idle)
+}
+
+func (c *cache[K, V]) SetWithExpireAfterAccessAndLifetime(key K, value V, idle, lifetime time.Duration) error {
+	return c.Cache.SetWithExpireAfterAccessAndLifetime(key, value, idle, lifetime)
+}
+
+func (c *cache[K, V]) Get(key K) (V, error) {
+	v, err := c.Cache.Get(key)
+	return valueOf[V](v), err
+}
+
+func (c *cache[K, V]) GetCtx(ctx context.Context, key K) (V, error) {
+	v, err := c.Cache.GetCtx(ctx, key)
+	return valueOf[V](v), err
+}
+
+func (c *cache[K, V]) GetIfPresent(key K) (V, error) {
+	v, err := c.Cache.GetIfPresent(key)
+	return valueOf[V](v), err
+}
+
+func (c *cache[K, V]) GetMany(keys []K) (map[K]V, error) {
+	ks := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		ks = append(ks, k)
+	}
+	m, err := c.Cache.GetMany(ks)
+	return itemsOf[K, V](m), err
+}
+
+func (c *cache[K, V]) SetMany(items map[K]V) error {
+	m := make(map[interface{}]interface{}, len(items))
+	for k, v := range items {
+		m[k] = v
+	}
+	return c.Cache.SetMany(m)
+}
+
+func (c *cache[K, V]) GetOrSet(key K, value V) (V, bool, error) {
+	v, loaded, err := c.Cache.GetOrSet(key, value)
+	return valueOf[V](v), loaded, err
+}
+
+func (c *cache[K, V]) Compute(key K, fn ComputeFunc[V]) (V, error) {
+	v, err := c.Cache.Compute(key, func(old interface{}, exists bool) (interface{}, bool) {
+		return fn(valueOf[V](old), exists)
+	})
+	return valueOf[V](v), err
+}
+
+func (c *cache[K, V]) CompareAndSwap(key K, old, new V) bool {
+	return c.Cache.CompareAndSwap(key, old, new)
+}
+
+// Incr key 不存在时从 V 的零值开始 这样新的值会是 V 类型而不是 int64
+// V 是接口类型时零值为 nil 此时从 int64(0) 开始
+func (c *cache[K, V]) Incr(key K, delta int64) (int64, error) {
+	var zero V
+	var initial interface{} = zero
+	if initial == nil {
+		initial = int64(0)
+	}
+	return c.Cache.IncrFrom(key, delta, initial)
+}
+
+func (c *cache[K, V]) Decr(key K, delta int64) (int64, error) {
+	return c.Incr(key, -delta)
+}
+
+func (c *cache[K, V]) GetALL(checkExpired bool) map[K]V {
+	return itemsOf[K, V](c.Cache.GetALL(checkExpired))
+}
+
+func (c *cache[K, V]) Keys(checkExpired bool) []K {
+	ks := c.Cache.Keys(checkExpired)
+	keys := make([]K, 0, len(ks))
+	for _, k := range ks {
+		keys = append(keys, k.(K))
+	}
+	return keys
+}
+
+func (c *cache[K, V]) Has(key K) bool {
+	return c.Cache.Has(key)
+}
+
+func (c *cache[K, V]) Remove(key K) bool {
+	return c.Cache.Remove(key)
+}
+
+// valueOf 把 interface{} 转换成 V
+// 值为 nil 时返回 V 的零值
+func valueOf[V any](v interface{}) V {
+	tv, _ := v.(V)
+	return tv
+}
+
+func itemsOf[K comparable, V any](m map[interface{}]interface{}) map[K]V {
+	items := make(map[K]V, len(m))
+	for k, v := range m {
+		items[k.(K)] = valueOf[V](v)
+	}
+	return items
+}
@@ -0,0 +1,158 @@
+package typed
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+var evictTypes = []string{TypeSimple, TypeLru, TypeLfu, TypeArc}
+
+func TestTypedGet(t *testing.T) {
+	for _, tp := range evictTypes {
+		t.Run(tp, func(t *testing.T) {
+			size := 100
+			gc := New[string, int](size).EvictType(tp).Build()
+			for i := 0; i < size; i++ {
+				if err := gc.Set(fmt.Sprintf("Key-%d", i), i); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i := 0; i < size; i++ {
+				v, err := gc.Get(fmt.Sprintf("Key-%d", i))
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if v != i {
+					t.Fatalf("%v != %v", v, i)
+				}
+			}
+			if _, err := gc.Get("missing"); err != KeyNotFoundError {
+				t.Fatalf("err should be KeyNotFoundError, not %v", err)
+			}
+			m := gc.GetALL(true)
+			if len(m) != size {
+				t.Fatalf("%v != %v", len(m), size)
+			}
+			if keys := gc.Keys(true); len(keys) != size {
+				t.Fatalf("%v != %v", len(keys), size)
+			}
+		})
+	}
+}
+
+func TestTypedLoader(t *testing.T) {
+	for _, tp := range evictTypes {
+		t.Run(tp, func(t *testing.T) {
+			var evicted []int
+			gc := New[int, string](2).
+				EvictType(tp).
+				LoaderFunc(func(k int) (string, error) {
+					return fmt.Sprint(k), nil
+				}).
+				EvictedFunc(func(k int, v string) {
+					evicted = append(evicted, k)
+				}).
+				Build()
+			for i := 0; i < 3; i++ {
+				v, err := gc.Get(i)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if v != fmt.Sprint(i) {
+					t.Fatalf("%v != %v", v, i)
+				}
+			}
+			if tp != TypeSimple && len(evicted) == 0 {
+				t.Fatal("evictedFunc should be called")
+			}
+		})
+	}
+}
+
+func TestTypedNilValue(t *testing.T) {
+	gc := New[string, error](8).LRU().Expiration(time.Minute).Build()
+	gc.Set("key", nil)
+	v, err := gc.Get("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != nil {
+		t.Fatalf("v should be nil, not %v", v)
+	}
+}
+
+func TestTypedGetMany(t *testing.T) {
+	for _, tp := range evictTypes {
+		t.Run(tp, func(t *testing.T) {
+			var calls int
+			gc := New[int, string](100).
+				EvictType(tp).
+				BatchLoaderFunc(func(keys []int) (map[int]string, error) {
+					calls++
+					m := make(map[int]string, len(keys))
+					for _, k := range keys {
+						m[k] = fmt.Sprint(k)
+					}
+					return m, nil
+				}).
+				Build()
+			gc.SetMany(map[int]string{0: "0", 1: "1"})
+			m, err := gc.GetMany([]int{0, 1, 2, 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(m) != 4 {
+				t.Fatalf("%v != 4", len(m))
+			}
+			for k, v := range m {
+				if v != fmt.Sprint(k) {
+					t.Fatalf("%v != %v", v, k)
+				}
+			}
+			if calls != 1 {
+				t.Fatalf("batch loader should be called once, not %v", calls)
+			}
+		})
+	}
+}
+
+func TestTypedCompute(t *testing.T) {
+	gc := New[string, int](8).LRU().Build()
+	for i := 0; i < 3; i++ {
+		gc.Compute("key", func(old int, exists bool) (int, bool) {
+			return old + 1, true
+		})
+	}
+	if v, _ := gc.Get("key"); v != 3 {
+		t.Fatalf("%v != 3", v)
+	}
+	if v, loaded, _ := gc.GetOrSet("key", 10); !loaded || v != 3 {
+		t.Fatalf("GetOrSet = %v, %v", v, loaded)
+	}
+	if !gc.CompareAndSwap("key", 3, 4) {
+		t.Fatal("CompareAndSwap should succeed")
+	}
+	if n, err := gc.Incr("counter", 2); err != nil || n != 2 {
+		t.Fatalf("Incr = %v, %v", n, err)
+	}
+	if v, _ := gc.Get("counter"); v != 2 {
+		t.Fatalf("%v != 2", v)
+	}
+}
+
+func TestTypedIncrNotInteger(t *testing.T) {
+	gc := New[string, string](8).LRU().Build()
+	if _, err := gc.Incr("k", 1); err != ValueNotIntegerError {
+		t.Fatalf("err should be ValueNotIntegerError, not %v", err)
+	}
+	if gc.Has("k") || gc.MissCount() != 0 {
+		t.Fatalf("a failed Incr should not change the cache, miss count %v", gc.MissCount())
+	}
+
+	bc := New[string, []byte](8).LRU().Build()
+	bc.Set("k", []byte("v"))
+	if bc.CompareAndSwap("k", []byte("v"), []byte("w")) {
+		t.Fatal("CompareAndSwap should fail on values that are not comparable")
+	}
+}
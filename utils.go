@@ -1,5 +1,7 @@
 package hyliocache
 
+import "reflect"
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -13,3 +15,65 @@ func max(a, b int) int {
 	}
 	return a
 }
+
+// subUint64 返回 a - b 结果小于 0 时返回 0
+func subUint64(a, b uint64) uint64 {
+	if a < b {
+		return 0
+	}
+	return a - b
+}
+
+// equal 用 == 比较 a 和 b 类型不同或者不可比较时返回 false 而不是 panic
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() || !va.Comparable() || !vb.Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// addInt 给整数 v 加上 delta 返回与 v 类型相同的结果以及它的 int64 值
+func addInt(v interface{}, delta int64) (interface{}, int64, error) {
+	switch n := v.(type) {
+	case int:
+		n += int(delta)
+		return n, int64(n), nil
+	case int8:
+		n += int8(delta)
+		return n, int64(n), nil
+	case int16:
+		n += int16(delta)
+		return n, int64(n), nil
+	case int32:
+		n += int32(delta)
+		return n, int64(n), nil
+	case int64:
+		n += delta
+		return n, n, nil
+	case uint:
+		n += uint(delta)
+		return n, int64(n), nil
+	case uint8:
+		n += uint8(delta)
+		return n, int64(n), nil
+	case uint16:
+		n += uint16(delta)
+		return n, int64(n), nil
+	case uint32:
+		n += uint32(delta)
+		return n, int64(n), nil
+	case uint64:
+		n += uint64(delta)
+		return n, int64(n), nil
+	default:
+		return nil, 0, ValueNotIntegerError
+	}
+}
+
+func divCeil(a, b int) int {
+	return (a + b - 1) / b
+}
@@ -0,0 +1,41 @@
+package hyliocache
+
+import "errors"
+
+/*
+weight 模块让缓存可以按照元素的重量限制容量
+没有设置 Weigher 时每个元素的重量都是 1
+设置了 MaxWeight 之后 各个淘汰策略会一直淘汰直到总重量不超过 MaxWeight
+*/
+
+var ItemTooHeavyError = errors.New("item is heavier than the max weight")
+
+// Weigher 返回元素的重量 重量不能为负数
+type Weigher func(key, value interface{}) int64
+
+// weigh 计算元素的重量 超过 maxWeight 时返回 ItemTooHeavyError
+func (c *baseCache) weigh(key, value interface{}) (int64, error) {
+	if c.weigher == nil {
+		return 1, nil
+	}
+	w := c.weigher(key, value)
+	limit := c.maxWeight
+	if c.maxItemWeight > 0 {
+		limit = c.maxItemWeight
+	}
+	if limit > 0 && w > limit {
+		return 0, ItemTooHeavyError
+	}
+	return w, nil
+}
+
+// setWeight 更新元素的重量以及缓存的总重量 调用时需持有 mu
+func (c *baseCache) setWeight(item *cacheItem, w int64) {
+	c.weight += w - item.weight
+	item.weight = w
+}
+
+// overweight 判断总重量是否超过了 maxWeight
+func (c *baseCache) overweight() bool {
+	return c.maxWeight > 0 && c.weight > c.maxWeight
+}
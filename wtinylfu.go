@@ -0,0 +1,206 @@
+package hyliocache
+
+import (
+	"container/list"
+	"hash/maphash"
+)
+
+/*
+wtinylfu 模块实现 W-TinyLFU 淘汰策略
+新的元素先进入一个很小的 LRU 窗口 被挤出窗口之后作为候选者
+只有比主缓存中最先被淘汰的元素更常用时才会进入主缓存
+主缓存是分为 probation 和 protected 两段的 SLRU
+访问频率由 count-min sketch 估计 并且会定期减半 让过去的热度逐渐被遗忘
+*/
+
+const (
+	segWindow = iota
+	segProbation
+	segProtected
+)
+
+type wTinyLFUNode struct {
+	key     interface{}
+	segment int
+}
+
+type wTinyLFU struct {
+	nodes        map[interface{}]*list.Element
+	window       *list.List
+	probation    *list.List
+	protected    *list.List
+	windowCap    int
+	mainCap      int
+	protectedCap int
+	sketch       *cmSketch
+}
+
+// newWTinyLFU 窗口占容量的 1% 主缓存中 protected 占 80%
+func newWTinyLFU(size int) *wTinyLFU {
+	windowCap := max(1, size/100)
+	mainCap := max(0, size-windowCap)
+	return &wTinyLFU{
+		nodes:        make(map[interface{}]*list.Element, size),
+		window:       list.New(),
+		probation:    list.New(),
+		protected:    list.New(),
+		windowCap:    windowCap,
+		mainCap:      mainCap,
+		protectedCap: mainCap * 8 / 10,
+		sketch:       newCMSketch(size),
+	}
+}
+
+func (p *wTinyLFU) OnInsert(key interface{}) {
+	p.sketch.Increment(key)
+	p.nodes[key] = p.window.PushFront(&wTinyLFUNode{key: key, segment: segWindow})
+}
+
+func (p *wTinyLFU) OnAccess(key interface{}) {
+	p.sketch.Increment(key)
+	e, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	switch e.Value.(*wTinyLFUNode).segment {
+	case segWindow:
+		p.window.MoveToFront(e)
+	case segProbation:
+		// probation 中的元素再次被访问时晋升到 protected
+		p.moveTo(e, segProtected)
+		if p.protected.Len() > p.protectedCap {
+			p.moveTo(p.protected.Back(), segProbation)
+		}
+	case segProtected:
+		p.protected.MoveToFront(e)
+	}
+}
+
+func (p *wTinyLFU) OnRemove(key interface{}) {
+	e, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	p.list(e.Value.(*wTinyLFUNode).segment).Remove(e)
+	delete(p.nodes, key)
+}
+
+func (p *wTinyLFU) Victim() (interface{}, bool) {
+	for p.window.Len() > p.windowCap {
+		candidate := p.window.Back()
+		if p.probation.Len()+p.protected.Len() < p.mainCap {
+			p.moveTo(candidate, segProbation)
+			continue
+		}
+		// 主缓存已满 候选者和主缓存中最先被淘汰的元素比较访问频率
+		victim := p.probation.Back()
+		if victim == nil {
+			victim = p.protected.Back()
+		}
+		if victim == nil {
+			return candidate.Value.(*wTinyLFUNode).key, true
+		}
+		ck, vk := candidate.Value.(*wTinyLFUNode).key, victim.Value.(*wTinyLFUNode).key
+		if p.sketch.Estimate(ck) > p.sketch.Estimate(vk) {
+			p.moveTo(candidate, segProbation)
+			return vk, true
+		}
+		return ck, true
+	}
+	for _, l := range []*list.List{p.probation, p.protected, p.window} {
+		if e := l.Back(); e != nil {
+			return e.Value.(*wTinyLFUNode).key, true
+		}
+	}
+	return nil, false
+}
+
+func (p *wTinyLFU) list(segment int) *list.List {
+	switch segment {
+	case segProbation:
+		return p.probation
+	case segProtected:
+		return p.protected
+	default:
+		return p.window
+	}
+}
+
+// moveTo 把 e 移动到 segment 的最前端
+func (p *wTinyLFU) moveTo(e *list.Element, segment int) {
+	node := e.Value.(*wTinyLFUNode)
+	p.list(node.segment).Remove(e)
+	node.segment = segment
+	p.nodes[node.key] = p.list(segment).PushFront(node)
+}
+
+const (
+	cmDepth   = 4
+	cmMaxFreq = 15
+)
+
+// cmSketch 是一个 4 行的 count-min sketch 每个计数器最大为 15
+// 增加的次数达到 resetAt 之后 所有计数器减半
+type cmSketch struct {
+	rows    [cmDepth][]uint8
+	mask    uint64
+	seed    maphash.Seed
+	added   int
+	resetAt int
+}
+
+func newCMSketch(size int) *cmSketch {
+	width := 64
+	for width < 4*size {
+		width <<= 1
+	}
+	s := &cmSketch{
+		mask:    uint64(width - 1),
+		seed:    maphash.MakeSeed(),
+		resetAt: 10 * max(size, 1),
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// index 使用两个哈希值的组合得到每一行的位置
+func (s *cmSketch) index(h uint64, row int) uint64 {
+	h1, h2 := h&0xffffffff, h>>32|1
+	return (h1 + uint64(row)*h2) & s.mask
+}
+
+func (s *cmSketch) Increment(key interface{}) {
+	h := maphash.Comparable(s.seed, key)
+	for i := range s.rows {
+		if c := &s.rows[i][s.index(h, i)]; *c < cmMaxFreq {
+			*c++
+		}
+	}
+	s.added++
+	if s.added >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *cmSketch) Estimate(key interface{}) uint8 {
+	h := maphash.Comparable(s.seed, key)
+	freq := uint8(cmMaxFreq)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(h, i)]; c < freq {
+			freq = c
+		}
+	}
+	return freq
+}
+
+// reset 所有计数器减半
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.added /= 2
+}
@@ -0,0 +1,166 @@
+package hyliocache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestWTinyLFUGet(t *testing.T) {
+	size := 1000
+	gc := buildTestCache(t, TypeWTinyLFU, size)
+	testSetCache(t, gc, size)
+	testGetCache(t, gc, size)
+}
+
+func TestLoadingWTinyLFUGet(t *testing.T) {
+	size := 1000
+	gc := buildTestLoadingCache(t, TypeWTinyLFU, size, loader)
+	testGetCache(t, gc, size)
+}
+
+func TestWTinyLFULength(t *testing.T) {
+	gc := buildTestLoadingCache(t, TypeWTinyLFU, 1000, loader)
+	gc.Get("test1")
+	gc.Get("test2")
+	length := gc.Len(true)
+	expectedLength := 2
+	if length != expectedLength {
+		t.Errorf("Expected length is %v, not %v", length, expectedLength)
+	}
+}
+
+func TestWTinyLFUEvictItem(t *testing.T) {
+	cacheSize := 10
+	numbers := 11
+	gc := buildTestLoadingCache(t, TypeWTinyLFU, cacheSize, loader)
+
+	for i := 0; i < numbers; i++ {
+		_, err := gc.Get(fmt.Sprintf("Key-%d", i))
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func TestWTinyLFUGetIFPresent(t *testing.T) {
+	testGetIFPresent(t, TypeWTinyLFU)
+}
+
+func TestWTinyLFUGetCtx(t *testing.T) {
+	testGetCtx(t, TypeWTinyLFU)
+}
+
+func TestWTinyLFUGetMany(t *testing.T) {
+	testGetMany(t, TypeWTinyLFU)
+}
+
+func TestWTinyLFUCompute(t *testing.T) {
+	testCompute(t, TypeWTinyLFU)
+}
+
+func TestWTinyLFURefreshAfterWrite(t *testing.T) {
+	testRefreshAfterWrite(t, TypeWTinyLFU)
+}
+
+func TestWTinyLFUStaleIfError(t *testing.T) {
+	testStaleIfError(t, TypeWTinyLFU)
+}
+
+func TestWTinyLFUNegativeTTL(t *testing.T) {
+	testNegativeTTL(t, TypeWTinyLFU)
+}
+
+func TestWTinyLFUWeigher(t *testing.T) {
+	testWeigher(t, TypeWTinyLFU)
+}
+
+func TestWTinyLFUExpireAfterAccess(t *testing.T) {
+	testExpireAfterAccess(t, TypeWTinyLFU)
+}
+
+func TestWTinyLFUExpiry(t *testing.T) {
+	testExpiry(t, TypeWTinyLFU)
+}
+
+func TestWTinyLFUCleanupInterval(t *testing.T) {
+	testCleanupInterval(t, TypeWTinyLFU)
+}
+
+func TestWTinyLFUEvictExpiredFirst(t *testing.T) {
+	testEvictExpiredFirst(t, TypeWTinyLFU)
+}
+
+func TestWTinyLFURemovalListener(t *testing.T) {
+	testRemovalListener(t, TypeWTinyLFU)
+}
+
+func TestWTinyLFUAsyncListeners(t *testing.T) {
+	testAsyncListeners(t, TypeWTinyLFU)
+}
+
+func TestWTinyLFUPurge(t *testing.T) {
+	testPurge(t, TypeWTinyLFU)
+}
+
+func TestWTinyLFUStats(t *testing.T) {
+	testStats(t, TypeWTinyLFU)
+}
+
+func TestWTinyLFUHas(t *testing.T) {
+	gc := buildTestLoadingCacheWithExpiration(t, TypeWTinyLFU, 2, 10*time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			gc.Get("test1")
+			gc.Get("test2")
+
+			if gc.Has("test0") {
+				t.Fatal("should not have test0")
+			}
+			if !gc.Has("test1") {
+				t.Fatal("should have test1")
+			}
+			if !gc.Has("test2") {
+				t.Fatal("should have test2")
+			}
+
+			time.Sleep(20 * time.Millisecond)
+
+			if gc.Has("test0") {
+				t.Fatal("should not have test0")
+			}
+			if gc.Has("test1") {
+				t.Fatal("should not have test1")
+			}
+			if gc.Has("test2") {
+				t.Fatal("should not have test2")
+			}
+		})
+	}
+}
+
+func TestWTinyLFUScanResistance(t *testing.T) {
+	size := 100
+	gc := buildTestCache(t, TypeWTinyLFU, size)
+	for i := 0; i < 10; i++ {
+		setItemsByRange(t, gc, 0, 50)
+		for j := 0; j < 50; j++ {
+			gc.Get(j)
+		}
+	}
+
+	setItemsByRange(t, gc, 1000, 2000)
+	if l := gc.Len(false); l != size {
+		t.Fatalf("%v != %v", l, size)
+	}
+	hot := 0
+	for i := 0; i < 50; i++ {
+		if gc.Has(i) {
+			hot++
+		}
+	}
+	if hot < 45 {
+		t.Fatalf("hot keys should survive the scan, only %v of 50 left", hot)
+	}
+}